@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
-	"log"
+	"flag"
+	"log/slog"
+	"os"
 	"os/signal"
 	"syscall"
 
+	"gofermart/internal/config"
 	"gofermart/internal/server"
 )
 
@@ -13,9 +16,24 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	app := server.NewApp()
+	config.SetConfig()
+	if args := flag.Args(); len(args) > 0 && args[0] == "migrate" {
+		if err := server.Migrate(ctx, args[1:], os.Stdout); err != nil {
+			slog.Error("migrate failed", "error", err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	app, err := server.NewApp()
+	if err != nil {
+		slog.Error("server initialization failed", "error", err)
+		os.Exit(1)
+	}
 	if err := app.Run(ctx); err != nil {
-		log.Fatalf("%s", err.Error())
+		slog.Error("server failed", "error", err)
+		os.Exit(1)
 	}
 
 }
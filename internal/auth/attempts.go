@@ -0,0 +1,266 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"gofermart/internal/models"
+)
+
+// AttemptStore keeps failed login counters. storage.Repository implements it
+// on top of PostgreSQL; MemoryAttemptStore is a single-instance alternative.
+type AttemptStore interface {
+	// UpdateLoginAttempts runs fn on the counters of keys while no other
+	// update can touch them. Missing counters are passed in empty; counters
+	// left without failures or a lock are deleted.
+	UpdateLoginAttempts(ctx context.Context, keys []string, fn func(attempts map[string]*models.LoginAttempt) error) error
+}
+
+type AuditLogger interface {
+	CreateAuditEvent(ctx context.Context, m *models.AuditEvent) error
+}
+
+type MemoryAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempt
+}
+
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+
+	return &MemoryAttemptStore{
+		attempts: map[string]models.LoginAttempt{},
+	}
+}
+
+func (s *MemoryAttemptStore) UpdateLoginAttempts(ctx context.Context, keys []string, fn func(attempts map[string]*models.LoginAttempt) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := make(map[string]*models.LoginAttempt, len(keys))
+	for _, key := range keys {
+		attempt := s.attempts[key]
+		attempt.Key = key
+		attempts[key] = &attempt
+	}
+	if err := fn(attempts); err != nil {
+
+		return err
+	}
+
+	now := time.Now()
+	for key, attempt := range attempts {
+		if attempt.Failures == 0 && !attempt.LockedUntil.After(now) {
+			delete(s.attempts, key)
+			continue
+		}
+		s.attempts[key] = *attempt
+	}
+
+	return nil
+}
+
+// AttemptPolicy describes how failures of one key are punished: after
+// FreeAttempts every failure doubles the wait before the next try, and
+// MaxFailures locks the key out for Lockout. Failures are forgotten once the
+// key has been quiet for Window.
+type AttemptPolicy struct {
+	FreeAttempts int
+	MaxFailures  int
+	BaseDelay    time.Duration
+	Lockout      time.Duration
+	Window       time.Duration
+}
+
+// decay clears the failures of a key quiet for longer than the window.
+func (p AttemptPolicy) decay(attempt *models.LoginAttempt, now time.Time) {
+	quietSince := attempt.LastFailureAt
+	if attempt.LockedUntil.After(quietSince) {
+		quietSince = attempt.LockedUntil
+	}
+	if p.Window > 0 && now.Sub(quietSince) > p.Window {
+		attempt.Failures = 0
+	}
+}
+
+func (p AttemptPolicy) wait(attempt *models.LoginAttempt, now time.Time) time.Duration {
+	if attempt.LockedUntil.After(now) {
+
+		return attempt.LockedUntil.Sub(now)
+	}
+	if attempt.Failures <= p.FreeAttempts {
+
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < attempt.Failures && delay < p.Lockout; i++ {
+		delay *= 2
+	}
+	if delay > p.Lockout {
+		delay = p.Lockout
+	}
+	if ready := attempt.LastFailureAt.Add(delay); ready.After(now) {
+
+		return ready.Sub(now)
+	}
+
+	return 0
+}
+
+// lockout doubles the lockout for every failure past MaxFailures, up to a day.
+func (p AttemptPolicy) lockout(failures int) time.Duration {
+	lockout := p.Lockout
+	for i := p.MaxFailures; i < failures && lockout < 24*time.Hour; i++ {
+		lockout *= 2
+	}
+	if lockout > 24*time.Hour {
+		lockout = 24 * time.Hour
+	}
+
+	return lockout
+}
+
+// LoginGuard throttles password guessing per login and per client IP.
+type LoginGuard struct {
+	store  AttemptStore
+	audit  AuditLogger
+	login  AttemptPolicy
+	remote AttemptPolicy
+}
+
+func NewLoginGuard(store AttemptStore, audit AuditLogger, login AttemptPolicy, remote AttemptPolicy) *LoginGuard {
+
+	return &LoginGuard{
+		store:  store,
+		audit:  audit,
+		login:  login,
+		remote: remote,
+	}
+}
+
+// Reserve checks whether the client may try a password now. If it may, the
+// attempt is counted as a failure right away, so parallel requests cannot
+// slip past the delay, and the caller must settle it with Fail or Succeed.
+// Otherwise nothing is counted and the wait is returned.
+func (g *LoginGuard) Reserve(ctx context.Context, login string, ip string) (time.Duration, error) {
+	now := time.Now()
+	policies := g.policies(login, ip)
+	wait := time.Duration(0)
+	err := g.store.UpdateLoginAttempts(ctx, keys(policies), func(attempts map[string]*models.LoginAttempt) error {
+		wait = 0
+		for key, policy := range policies {
+			policy.decay(attempts[key], now)
+			if d := policy.wait(attempts[key], now); d > wait {
+				wait = d
+			}
+		}
+		if wait > 0 {
+
+			return nil
+		}
+		for key := range policies {
+			attempts[key].Failures++
+			attempts[key].LastFailureAt = now
+		}
+
+		return nil
+	})
+
+	return wait, err
+}
+
+// Fail settles a reserved attempt as failed and locks out keys that reached
+// their maximum.
+func (g *LoginGuard) Fail(ctx context.Context, login string, ip string) error {
+	now := time.Now()
+	policies := g.policies(login, ip)
+	events := []*models.AuditEvent{}
+	err := g.store.UpdateLoginAttempts(ctx, keys(policies), func(attempts map[string]*models.LoginAttempt) error {
+		events = events[:0]
+		for key, policy := range policies {
+			attempt := attempts[key]
+			if attempt.Failures < policy.MaxFailures || attempt.LockedUntil.After(now) {
+				continue
+			}
+			attempt.LockedUntil = now.Add(policy.lockout(attempt.Failures))
+			events = append(events, &models.AuditEvent{
+				Kind:    models.AuditLoginLockout,
+				Subject: key,
+				IP:      ip,
+				Details: fmt.Sprintf("%d failed attempts, locked until %s",
+					attempt.Failures, attempt.LockedUntil.Format(time.RFC3339)),
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+
+		return err
+	}
+	for _, event := range events {
+		if err := g.audit.CreateAuditEvent(ctx, event); err != nil {
+			slog.Error("audit event saving failed", "kind", event.Kind, "subject", event.Subject, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// Succeed settles a reserved attempt as successful: the login counter is
+// cleared and the IP gets its reserved failure back. The rest of the IP
+// counter is kept, otherwise an attacker could reset it with an account of
+// their own; it decays with the policy window instead.
+func (g *LoginGuard) Succeed(ctx context.Context, login string, ip string) error {
+	userKey, remoteKey := loginKey(login), ipKey(ip)
+
+	return g.store.UpdateLoginAttempts(ctx, []string{userKey, remoteKey}, func(attempts map[string]*models.LoginAttempt) error {
+		*attempts[userKey] = models.LoginAttempt{Key: userKey}
+		if attempts[remoteKey].Failures > 0 {
+			attempts[remoteKey].Failures--
+		}
+
+		return nil
+	})
+}
+
+// Reset clears the login counter and lockout, as after a password reset.
+func (g *LoginGuard) Reset(ctx context.Context, login string) error {
+	key := loginKey(login)
+
+	return g.store.UpdateLoginAttempts(ctx, []string{key}, func(attempts map[string]*models.LoginAttempt) error {
+		*attempts[key] = models.LoginAttempt{Key: key}
+
+		return nil
+	})
+}
+
+func (g *LoginGuard) policies(login string, ip string) map[string]AttemptPolicy {
+
+	return map[string]AttemptPolicy{
+		loginKey(login): g.login,
+		ipKey(ip):       g.remote,
+	}
+}
+
+func keys(policies map[string]AttemptPolicy) []string {
+	keys := make([]string, 0, len(policies))
+	for key := range policies {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func loginKey(login string) string {
+
+	return "login:" + strings.ToLower(strings.TrimSpace(login))
+}
+
+func ipKey(ip string) string {
+
+	return "ip:" + ip
+}
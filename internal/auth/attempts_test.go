@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gofermart/internal/models"
+)
+
+var testPolicy = AttemptPolicy{
+	FreeAttempts: 3,
+	MaxFailures:  10,
+	BaseDelay:    time.Second,
+	Lockout:      15 * time.Minute,
+	Window:       time.Hour,
+}
+
+func TestAttemptPolicyWait(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		failures    int
+		lastFailure time.Duration
+		lockedFor   time.Duration
+		want        time.Duration
+	}{
+		{0, 0, 0, 0},
+		{3, 0, 0, 0},
+		{4, 0, 0, time.Second},
+		{5, 0, 0, 2 * time.Second},
+		{6, 0, 0, 4 * time.Second},
+		{6, 3 * time.Second, 0, time.Second},
+		{6, 5 * time.Second, 0, 0},
+		{30, 0, 0, 15 * time.Minute},
+		{10, 0, 5 * time.Minute, 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		attempt := &models.LoginAttempt{
+			Failures:      tt.failures,
+			LastFailureAt: now.Add(-tt.lastFailure),
+		}
+		if tt.lockedFor > 0 {
+			attempt.LockedUntil = now.Add(tt.lockedFor)
+		}
+		if got := testPolicy.wait(attempt, now); got != tt.want {
+			t.Errorf("wait(%d failures, last %v ago, locked for %v) = %v, want %v",
+				tt.failures, tt.lastFailure, tt.lockedFor, got, tt.want)
+		}
+	}
+}
+
+func TestAttemptPolicyLockout(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{10, 15 * time.Minute},
+		{11, 30 * time.Minute},
+		{12, time.Hour},
+		{16, 16 * time.Hour},
+		{17, 24 * time.Hour},
+		{100, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := testPolicy.lockout(tt.failures); got != tt.want {
+			t.Errorf("lockout(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestAttemptPolicyDecay(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		lastFailure time.Duration
+		lockedUntil time.Duration
+		want        int
+	}{
+		{30 * time.Minute, 0, 5},
+		{2 * time.Hour, 0, 0},
+		{2 * time.Hour, -30 * time.Minute, 5},
+		{3 * time.Hour, -90 * time.Minute, 0},
+	}
+	for _, tt := range tests {
+		attempt := &models.LoginAttempt{
+			Failures:      5,
+			LastFailureAt: now.Add(-tt.lastFailure),
+		}
+		if tt.lockedUntil != 0 {
+			attempt.LockedUntil = now.Add(tt.lockedUntil)
+		}
+		testPolicy.decay(attempt, now)
+		if attempt.Failures != tt.want {
+			t.Errorf("decay(last failure %v ago, locked until %v) left %d failures, want %d",
+				tt.lastFailure, tt.lockedUntil, attempt.Failures, tt.want)
+		}
+	}
+}
+
+type auditRecorder struct {
+	events []*models.AuditEvent
+}
+
+func (a *auditRecorder) CreateAuditEvent(_ context.Context, m *models.AuditEvent) error {
+	a.events = append(a.events, m)
+
+	return nil
+}
+
+func TestLoginGuardLockout(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAttemptStore()
+	audit := &auditRecorder{}
+	login := AttemptPolicy{FreeAttempts: 3, MaxFailures: 3, BaseDelay: time.Second, Lockout: 15 * time.Minute, Window: time.Hour}
+	guard := NewLoginGuard(store, audit, login, testPolicy)
+
+	for i := 1; i <= login.MaxFailures; i++ {
+		wait, err := guard.Reserve(ctx, "Alice", "10.0.0.1")
+		if err != nil || wait != 0 {
+			t.Fatalf("Reserve #%d = %v, %v, want a free attempt", i, wait, err)
+		}
+		if err := guard.Fail(ctx, "Alice", "10.0.0.1"); err != nil {
+			t.Fatalf("Fail #%d: %v", i, err)
+		}
+	}
+
+	if len(audit.events) != 1 {
+		t.Fatalf("got %d audit events, want 1", len(audit.events))
+	}
+	event := audit.events[0]
+	if event.Kind != models.AuditLoginLockout || event.Subject != "login:alice" || event.IP != "10.0.0.1" {
+		t.Errorf("audit event = %+v", event)
+	}
+	wait, err := guard.Reserve(ctx, " alice ", "10.0.0.2")
+	if err != nil {
+		t.Fatalf("Reserve after lockout: %v", err)
+	}
+	if wait < login.Lockout-time.Second || wait > login.Lockout {
+		t.Errorf("Reserve after lockout waits %v, want %v", wait, login.Lockout)
+	}
+	if failures := store.attempts["login:alice"].Failures; failures != login.MaxFailures {
+		t.Errorf("a refused attempt was counted: %d failures, want %d", failures, login.MaxFailures)
+	}
+}
+
+func TestLoginGuardSucceed(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAttemptStore()
+	guard := NewLoginGuard(store, &auditRecorder{}, testPolicy, testPolicy)
+
+	for _, login := range []string{"alice", "bob"} {
+		if _, err := guard.Reserve(ctx, login, "10.0.0.1"); err != nil {
+			t.Fatalf("Reserve(%s): %v", login, err)
+		}
+		if err := guard.Fail(ctx, login, "10.0.0.1"); err != nil {
+			t.Fatalf("Fail(%s): %v", login, err)
+		}
+	}
+	if _, err := guard.Reserve(ctx, "alice", "10.0.0.1"); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	if err := guard.Succeed(ctx, "alice", "10.0.0.1"); err != nil {
+		t.Fatalf("Succeed: %v", err)
+	}
+
+	if attempt, ok := store.attempts["login:alice"]; ok {
+		t.Errorf("login counter kept after success: %+v", attempt)
+	}
+	if failures := store.attempts["login:bob"].Failures; failures != 1 {
+		t.Errorf("login:bob has %d failures, want 1", failures)
+	}
+	if failures := store.attempts["ip:10.0.0.1"].Failures; failures != 2 {
+		t.Errorf("ip counter has %d failures, want 2: only the reserved one is given back", failures)
+	}
+}
+
+func TestLoginGuardDecay(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAttemptStore()
+	guard := NewLoginGuard(store, &auditRecorder{}, testPolicy, testPolicy)
+	store.attempts["login:alice"] = models.LoginAttempt{
+		Key:           "login:alice",
+		Failures:      8,
+		LastFailureAt: time.Now().Add(-2 * testPolicy.Window),
+	}
+
+	wait, err := guard.Reserve(ctx, "alice", "10.0.0.1")
+	if err != nil || wait != 0 {
+		t.Fatalf("Reserve = %v, %v, want a free attempt", wait, err)
+	}
+	if failures := store.attempts["login:alice"].Failures; failures != 1 {
+		t.Errorf("login:alice has %d failures, want 1 after decay", failures)
+	}
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"context"
+
+	"gofermart/internal/models"
+)
+
+type contextKey struct{}
+
+type sessionContextKey struct{}
+
+func WithUser(ctx context.Context, user *models.User) context.Context {
+
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// UserFromContext returns the user authenticated by the auth middleware. It is
+// never nil inside handlers mounted behind the middleware.
+func UserFromContext(ctx context.Context) *models.User {
+	user, _ := ctx.Value(contextKey{}).(*models.User)
+
+	return user
+}
+
+func WithSession(ctx context.Context, session *models.Session) context.Context {
+
+	return context.WithValue(ctx, sessionContextKey{}, session)
+}
+
+func SessionFromContext(ctx context.Context) *models.Session {
+	session, _ := ctx.Value(sessionContextKey{}).(*models.Session)
+
+	return session
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"gofermart/internal/models"
+	"gofermart/internal/storage"
+)
+
+// RefreshTokens issues opaque single-use refresh tokens. Every rotation
+// replaces the presented token with a new one of the same family.
+type RefreshTokens struct {
+	repo storage.Repository
+	ttl  time.Duration
+}
+
+func NewRefreshTokens(repo storage.Repository, ttl time.Duration) *RefreshTokens {
+
+	return &RefreshTokens{
+		repo: repo,
+		ttl:  ttl,
+	}
+}
+
+// Issue starts a new token family for a freshly opened session.
+func (t *RefreshTokens) Issue(ctx context.Context, userID uint64, sessionID string) (string, time.Time, error) {
+	raw, err := randomID()
+	if err != nil {
+
+		return "", time.Time{}, err
+	}
+	family, err := randomID()
+	if err != nil {
+
+		return "", time.Time{}, err
+	}
+	token := &models.RefreshToken{
+		UserID:    userID,
+		SessionID: sessionID,
+		FamilyID:  family,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(t.ttl),
+	}
+	if err := t.repo.CreateRefreshToken(ctx, token); err != nil {
+
+		return "", time.Time{}, err
+	}
+
+	return raw, token.ExpiresAt, nil
+}
+
+// Rotate exchanges the raw token for a new one. The returned model is the
+// successor; on storage.ErrRefreshTokenReused it is the reused token, so the
+// caller knows which session to kill.
+func (t *RefreshTokens) Rotate(ctx context.Context, raw string) (string, *models.RefreshToken, error) {
+	next, err := randomID()
+	if err != nil {
+
+		return "", nil, err
+	}
+	token := &models.RefreshToken{
+		TokenHash: hashToken(next),
+		ExpiresAt: time.Now().Add(t.ttl),
+	}
+	current, err := t.repo.RotateRefreshToken(ctx, hashToken(raw), token)
+	if err != nil {
+
+		return "", current, err
+	}
+
+	return next, token, nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"gofermart/internal/models"
+	"gofermart/internal/storage"
+)
+
+// PasswordResets issues single-use expiring password reset tokens.
+type PasswordResets struct {
+	repo storage.Repository
+	ttl  time.Duration
+}
+
+func NewPasswordResets(repo storage.Repository, ttl time.Duration) *PasswordResets {
+
+	return &PasswordResets{
+		repo: repo,
+		ttl:  ttl,
+	}
+}
+
+func (r *PasswordResets) Issue(ctx context.Context, userID uint64) (string, time.Time, error) {
+	raw, err := randomID()
+	if err != nil {
+
+		return "", time.Time{}, err
+	}
+	reset := &models.PasswordReset{
+		UserID:    userID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(r.ttl),
+	}
+	if err := r.repo.CreatePasswordReset(ctx, reset); err != nil {
+
+		return "", time.Time{}, err
+	}
+
+	return raw, reset.ExpiresAt, nil
+}
+
+// Consume spends the token, sets the password hash of the user it was issued
+// for and returns that user. A token is spent only if the password is stored.
+func (r *PasswordResets) Consume(ctx context.Context, raw string, passwordHash string) (uint64, error) {
+	reset, err := r.repo.UsePasswordReset(ctx, hashToken(raw), passwordHash)
+	if err != nil {
+
+		return 0, err
+	}
+
+	return reset.UserID, nil
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"gofermart/internal/models"
+	"gofermart/internal/storage"
+)
+
+const (
+	// sessions are re-read from the database after this long, so revocations
+	// made by other instances are picked up
+	sessionCacheTTL = 30 * time.Second
+	// sliding renewal is written to the database at most this often
+	sessionRenewInterval = time.Minute
+)
+
+var ErrSessionExpired = errors.New("session expired or revoked")
+
+type cachedSession struct {
+	session  models.Session
+	cachedAt time.Time
+}
+
+// Sessions keeps server-side sessions in PostgreSQL with an in-memory cache in
+// front of it. Every authenticated request slides the session expiry.
+type Sessions struct {
+	repo     storage.Repository
+	ttl      time.Duration
+	mu       sync.RWMutex
+	cache    map[string]cachedSession
+	prunedAt time.Time
+}
+
+func NewSessions(repo storage.Repository, ttl time.Duration) *Sessions {
+
+	return &Sessions{
+		repo:  repo,
+		ttl:   ttl,
+		cache: map[string]cachedSession{},
+	}
+}
+
+func (s *Sessions) Start(ctx context.Context, userID uint64, userAgent string, ip string) (*models.Session, error) {
+	id, err := randomID()
+	if err != nil {
+
+		return nil, err
+	}
+	now := time.Now()
+	session := &models.Session{
+		ID:         id,
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.ttl),
+	}
+	if err := s.repo.CreateSession(ctx, session); err != nil {
+
+		return nil, err
+	}
+	s.store(*session)
+
+	return session, nil
+}
+
+func (s *Sessions) Get(ctx context.Context, id string) (*models.Session, error) {
+	now := time.Now()
+
+	s.mu.RLock()
+	cached, ok := s.cache[id]
+	s.mu.RUnlock()
+
+	session := &cached.session
+	if !ok || now.Sub(cached.cachedAt) > sessionCacheTTL {
+		session = s.repo.GetSession(ctx, id)
+		if session == nil {
+			s.forget(id)
+
+			return nil, ErrSessionExpired
+		}
+		s.store(*session)
+	}
+	if !session.Active(now) {
+		s.forget(id)
+
+		return nil, ErrSessionExpired
+	}
+
+	return session, nil
+}
+
+// Touch slides the server-side session expiry forward. Access tokens are not
+// renewed here: a client gets a new one only by rotating its refresh token.
+func (s *Sessions) Touch(ctx context.Context, session *models.Session) error {
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) < sessionRenewInterval {
+
+		return nil
+	}
+	session.LastSeenAt = now
+	session.ExpiresAt = now.Add(s.ttl)
+	if err := s.repo.TouchSession(ctx, session.ID, session.LastSeenAt, session.ExpiresAt); err != nil {
+
+		return err
+	}
+	s.store(*session)
+
+	return nil
+}
+
+func (s *Sessions) Revoke(ctx context.Context, id string) error {
+	s.forget(id)
+
+	return s.repo.RevokeSession(ctx, id)
+}
+
+// RevokeAll logs the user out of every device except the session given.
+func (s *Sessions) RevokeAll(ctx context.Context, userID uint64, except string) error {
+	ids, err := s.repo.RevokeUserSessions(ctx, userID, except)
+	for _, id := range ids {
+		s.forget(id)
+	}
+
+	return err
+}
+
+func (s *Sessions) List(ctx context.Context, userID uint64) []models.Session {
+
+	return s.repo.GetSessions(ctx, userID)
+}
+
+func (s *Sessions) store(session models.Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.cache[session.ID] = cachedSession{
+		session:  session,
+		cachedAt: now,
+	}
+	if now.Sub(s.prunedAt) > sessionCacheTTL {
+		for id, cached := range s.cache {
+			if now.Sub(cached.cachedAt) > sessionCacheTTL {
+				delete(s.cache, id)
+			}
+		}
+		s.prunedAt = now
+	}
+}
+
+func (s *Sessions) forget(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.cache, id)
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	CookieName        = "user"
+	RefreshCookieName = "refresh"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+type Claims struct {
+	jwt.RegisteredClaims
+	UserID    uint64 `json:"uid"`
+	SessionID string `json:"sid"`
+}
+
+// TokenManager issues and verifies short-lived HMAC-SHA256 signed access
+// tokens bound to a server-side session.
+type TokenManager struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func NewTokenManager(secret []byte, ttl time.Duration) *TokenManager {
+
+	return &TokenManager{
+		secret: secret,
+		ttl:    ttl,
+	}
+}
+
+// Issue signs an access token that never outlives its session.
+func (m *TokenManager) Issue(userID uint64, sessionID string, sessionExpires time.Time) (string, time.Time, error) {
+	expires := time.Now().Add(m.ttl)
+	if sessionExpires.Before(expires) {
+		expires = sessionExpires
+	}
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(userID, 10),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expires),
+		},
+		UserID:    userID,
+		SessionID: sessionID,
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+	if err != nil {
+
+		return "", time.Time{}, err
+	}
+
+	return token, expires, nil
+}
+
+func (m *TokenManager) Verify(token string) (*Claims, error) {
+	claims := &Claims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+
+		return m.secret, nil
+	})
+	if err != nil || !parsed.Valid || claims.UserID == 0 || claims.SessionID == "" {
+
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// TokenFromRequest takes the token from the "Authorization: Bearer" header,
+// falling back to the session cookie.
+func TokenFromRequest(req *http.Request) string {
+	if header := req.Header.Get("Authorization"); header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+
+			return strings.TrimSpace(token)
+		}
+	}
+	if cookie, err := req.Cookie(CookieName); err == nil {
+
+		return cookie.Value
+	}
+
+	return ""
+}
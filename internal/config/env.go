@@ -3,21 +3,82 @@ package config
 import (
 	"flag"
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
-	ServerAddress  string `env:"RUN_ADDRESS"`
-	AccrualAddress string `env:"ACCRUAL_SYSTEM_ADDRESS"`
-	DBAddress      string `env:"DATABASE_URI"`
+	ServerAddress  string        `env:"RUN_ADDRESS"`
+	AccrualAddress string        `env:"ACCRUAL_SYSTEM_ADDRESS"`
+	DBAddress      string        `env:"DATABASE_URI"`
+	AccrualWorkers int           `env:"ACCRUAL_WORKERS"`
+	AccrualBatch   int           `env:"ACCRUAL_BATCH_SIZE"`
+	AccrualMaxAge  time.Duration `env:"ACCRUAL_ORDER_MAX_AGE"`
+	SecretKey      string        `env:"SECRET_KEY"`
+	SessionTTL     time.Duration `env:"SESSION_TTL"`
+	AccessTTL      time.Duration `env:"ACCESS_TOKEN_TTL"`
+	RefreshTTL     time.Duration `env:"REFRESH_TOKEN_TTL"`
+	LoginFailures  int           `env:"LOGIN_MAX_FAILURES"`
+	LoginLockout   time.Duration `env:"LOGIN_LOCKOUT"`
+	AttemptsWindow time.Duration `env:"LOGIN_ATTEMPTS_WINDOW"`
+	AttemptsStore  string        `env:"LOGIN_ATTEMPTS_STORE"`
+	ResetTTL       time.Duration `env:"PASSWORD_RESET_TTL"`
+	Notifier       string        `env:"NOTIFIER"`
+	NotifierPath   string        `env:"NOTIFIER_PATH"`
+	LoginMinLength int           `env:"LOGIN_MIN_LENGTH"`
+	LoginMaxLength int           `env:"LOGIN_MAX_LENGTH"`
+	LoginPattern   string        `env:"LOGIN_PATTERN"`
+	PasswordMinLen int           `env:"PASSWORD_MIN_LENGTH"`
+	PasswordDigit  bool          `env:"PASSWORD_REQUIRE_DIGIT"`
+	PasswordCase   bool          `env:"PASSWORD_REQUIRE_MIXED_CASE"`
+	PasswordSymbol bool          `env:"PASSWORD_REQUIRE_SYMBOL"`
+	LogLevel       string        `env:"LOG_LEVEL"`
+	LogFormat      string        `env:"LOG_FORMAT"`
+	LogOutput      string        `env:"LOG_OUTPUT"`
+	TraceExporter  string        `env:"TRACE_EXPORTER"`
+	TraceEndpoint  string        `env:"TRACE_ENDPOINT"`
+	ReadyPollAge   time.Duration `env:"READY_MAX_POLL_AGE"`
+	AutoMigrate    bool          `env:"AUTO_MIGRATE"`
 }
 
 var ServerConfig Config
-var Secretkey = []byte("Diplom_Go-13_Info")
+
+// Secretkey signs access tokens. It has no default: a key published with the
+// source would let anyone forge tokens.
+var Secretkey []byte
 
 func SetConfig() Config {
 	addr := flag.String("a", "localhost:8080", "RUN_ADDRESS")
 	base := flag.String("d", "", "ACCRUAL_SYSTEM_ADDRESS")
 	db := flag.String("r", "host=localhost port=5432 user=postgres password= dbname=postgres sslmode=disable", "DATABASE_URI")
+	workers := flag.Int("w", 4, "ACCRUAL_WORKERS")
+	batch := flag.Int("b", 100, "ACCRUAL_BATCH_SIZE")
+	maxAge := flag.Duration("m", 24*time.Hour, "ACCRUAL_ORDER_MAX_AGE")
+	secret := flag.String("k", "", "SECRET_KEY")
+	sessionTTL := flag.Duration("t", 30*24*time.Hour, "SESSION_TTL")
+	accessTTL := flag.Duration("access-ttl", 15*time.Minute, "ACCESS_TOKEN_TTL")
+	refreshTTL := flag.Duration("refresh-ttl", 30*24*time.Hour, "REFRESH_TOKEN_TTL")
+	loginFailures := flag.Int("login-max-failures", 10, "LOGIN_MAX_FAILURES")
+	loginLockout := flag.Duration("login-lockout", 15*time.Minute, "LOGIN_LOCKOUT")
+	attemptsWindow := flag.Duration("login-attempts-window", time.Hour, "LOGIN_ATTEMPTS_WINDOW")
+	attemptsStore := flag.String("login-attempts-store", "postgres", "LOGIN_ATTEMPTS_STORE")
+	resetTTL := flag.Duration("password-reset-ttl", time.Hour, "PASSWORD_RESET_TTL")
+	notifier := flag.String("notifier", "outbox", "NOTIFIER")
+	notifierPath := flag.String("notifier-path", "outbox.log", "NOTIFIER_PATH")
+	loginMin := flag.Int("login-min-length", 3, "LOGIN_MIN_LENGTH")
+	loginMax := flag.Int("login-max-length", 64, "LOGIN_MAX_LENGTH")
+	loginPattern := flag.String("login-pattern", `^[a-zA-Z0-9._@+-]+$`, "LOGIN_PATTERN")
+	passwordMin := flag.Int("password-min-length", 6, "PASSWORD_MIN_LENGTH")
+	passwordDigit := flag.Bool("password-require-digit", false, "PASSWORD_REQUIRE_DIGIT")
+	passwordCase := flag.Bool("password-require-mixed-case", false, "PASSWORD_REQUIRE_MIXED_CASE")
+	passwordSymbol := flag.Bool("password-require-symbol", false, "PASSWORD_REQUIRE_SYMBOL")
+	logLevel := flag.String("log-level", "info", "LOG_LEVEL")
+	logFormat := flag.String("log-format", "json", "LOG_FORMAT")
+	logOutput := flag.String("log-output", "stdout", "LOG_OUTPUT")
+	traceExporter := flag.String("trace-exporter", "none", "TRACE_EXPORTER")
+	traceEndpoint := flag.String("trace-endpoint", "", "TRACE_ENDPOINT")
+	readyPollAge := flag.Duration("ready-max-poll-age", 5*time.Minute, "READY_MAX_POLL_AGE")
+	autoMigrate := flag.Bool("auto-migrate", true, "AUTO_MIGRATE")
 	flag.Parse()
 
 	if serverAddress := os.Getenv("RUN_ADDRESS"); serverAddress == "" {
@@ -38,6 +99,157 @@ func SetConfig() Config {
 		ServerConfig.DBAddress = dbAddress
 	}
 
+	ServerConfig.AccrualWorkers = *workers
+	if accrualWorkers, err := strconv.Atoi(os.Getenv("ACCRUAL_WORKERS")); err == nil && accrualWorkers > 0 {
+		ServerConfig.AccrualWorkers = accrualWorkers
+	}
+
+	ServerConfig.AccrualBatch = *batch
+	if accrualBatch, err := strconv.Atoi(os.Getenv("ACCRUAL_BATCH_SIZE")); err == nil && accrualBatch > 0 {
+		ServerConfig.AccrualBatch = accrualBatch
+	}
+
+	ServerConfig.AccrualMaxAge = *maxAge
+	if accrualMaxAge, err := time.ParseDuration(os.Getenv("ACCRUAL_ORDER_MAX_AGE")); err == nil && accrualMaxAge > 0 {
+		ServerConfig.AccrualMaxAge = accrualMaxAge
+	}
+
+	if secretKey := os.Getenv("SECRET_KEY"); secretKey == "" {
+		ServerConfig.SecretKey = *secret
+	} else {
+		ServerConfig.SecretKey = secretKey
+	}
+	Secretkey = []byte(ServerConfig.SecretKey)
+
+	ServerConfig.SessionTTL = *sessionTTL
+	if ttl, err := time.ParseDuration(os.Getenv("SESSION_TTL")); err == nil && ttl > 0 {
+		ServerConfig.SessionTTL = ttl
+	}
+
+	ServerConfig.AccessTTL = *accessTTL
+	if ttl, err := time.ParseDuration(os.Getenv("ACCESS_TOKEN_TTL")); err == nil && ttl > 0 {
+		ServerConfig.AccessTTL = ttl
+	}
+
+	ServerConfig.RefreshTTL = *refreshTTL
+	if ttl, err := time.ParseDuration(os.Getenv("REFRESH_TOKEN_TTL")); err == nil && ttl > 0 {
+		ServerConfig.RefreshTTL = ttl
+	}
+
+	ServerConfig.LoginFailures = *loginFailures
+	if failures, err := strconv.Atoi(os.Getenv("LOGIN_MAX_FAILURES")); err == nil && failures > 0 {
+		ServerConfig.LoginFailures = failures
+	}
+
+	ServerConfig.LoginLockout = *loginLockout
+	if lockout, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT")); err == nil && lockout > 0 {
+		ServerConfig.LoginLockout = lockout
+	}
+
+	ServerConfig.AttemptsWindow = *attemptsWindow
+	if window, err := time.ParseDuration(os.Getenv("LOGIN_ATTEMPTS_WINDOW")); err == nil && window > 0 {
+		ServerConfig.AttemptsWindow = window
+	}
+
+	if store := os.Getenv("LOGIN_ATTEMPTS_STORE"); store == "" {
+		ServerConfig.AttemptsStore = *attemptsStore
+	} else {
+		ServerConfig.AttemptsStore = store
+	}
+
+	ServerConfig.ResetTTL = *resetTTL
+	if ttl, err := time.ParseDuration(os.Getenv("PASSWORD_RESET_TTL")); err == nil && ttl > 0 {
+		ServerConfig.ResetTTL = ttl
+	}
+
+	if notifierKind := os.Getenv("NOTIFIER"); notifierKind == "" {
+		ServerConfig.Notifier = *notifier
+	} else {
+		ServerConfig.Notifier = notifierKind
+	}
+
+	if path := os.Getenv("NOTIFIER_PATH"); path == "" {
+		ServerConfig.NotifierPath = *notifierPath
+	} else {
+		ServerConfig.NotifierPath = path
+	}
+
+	ServerConfig.LoginMinLength = *loginMin
+	if length, err := strconv.Atoi(os.Getenv("LOGIN_MIN_LENGTH")); err == nil && length > 0 {
+		ServerConfig.LoginMinLength = length
+	}
+
+	ServerConfig.LoginMaxLength = *loginMax
+	if length, err := strconv.Atoi(os.Getenv("LOGIN_MAX_LENGTH")); err == nil && length > 0 {
+		ServerConfig.LoginMaxLength = length
+	}
+
+	if pattern := os.Getenv("LOGIN_PATTERN"); pattern == "" {
+		ServerConfig.LoginPattern = *loginPattern
+	} else {
+		ServerConfig.LoginPattern = pattern
+	}
+
+	ServerConfig.PasswordMinLen = *passwordMin
+	if length, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && length > 0 {
+		ServerConfig.PasswordMinLen = length
+	}
+
+	ServerConfig.PasswordDigit = *passwordDigit
+	if required, err := strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_DIGIT")); err == nil {
+		ServerConfig.PasswordDigit = required
+	}
+
+	ServerConfig.PasswordCase = *passwordCase
+	if required, err := strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_MIXED_CASE")); err == nil {
+		ServerConfig.PasswordCase = required
+	}
+
+	ServerConfig.PasswordSymbol = *passwordSymbol
+	if required, err := strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_SYMBOL")); err == nil {
+		ServerConfig.PasswordSymbol = required
+	}
+
+	if level := os.Getenv("LOG_LEVEL"); level == "" {
+		ServerConfig.LogLevel = *logLevel
+	} else {
+		ServerConfig.LogLevel = level
+	}
+
+	if format := os.Getenv("LOG_FORMAT"); format == "" {
+		ServerConfig.LogFormat = *logFormat
+	} else {
+		ServerConfig.LogFormat = format
+	}
+
+	if output := os.Getenv("LOG_OUTPUT"); output == "" {
+		ServerConfig.LogOutput = *logOutput
+	} else {
+		ServerConfig.LogOutput = output
+	}
+
+	if exporter := os.Getenv("TRACE_EXPORTER"); exporter == "" {
+		ServerConfig.TraceExporter = *traceExporter
+	} else {
+		ServerConfig.TraceExporter = exporter
+	}
+
+	if endpoint := os.Getenv("TRACE_ENDPOINT"); endpoint == "" {
+		ServerConfig.TraceEndpoint = *traceEndpoint
+	} else {
+		ServerConfig.TraceEndpoint = endpoint
+	}
+
+	ServerConfig.ReadyPollAge = *readyPollAge
+	if age, err := time.ParseDuration(os.Getenv("READY_MAX_POLL_AGE")); err == nil {
+		ServerConfig.ReadyPollAge = age
+	}
+
+	ServerConfig.AutoMigrate = *autoMigrate
+	if migrate, err := strconv.ParseBool(os.Getenv("AUTO_MIGRATE")); err == nil {
+		ServerConfig.AutoMigrate = migrate
+	}
+
 	return ServerConfig
 }
 
@@ -56,7 +268,142 @@ func GetConfigDBAddress() string {
 	return ServerConfig.DBAddress
 }
 
-func GetConfigPath() string {
+func GetConfigAccrualWorkers() int {
+
+	return ServerConfig.AccrualWorkers
+}
+
+func GetConfigAccrualBatch() int {
+
+	return ServerConfig.AccrualBatch
+}
+
+func GetConfigAccrualMaxAge() time.Duration {
+
+	return ServerConfig.AccrualMaxAge
+}
+
+func GetConfigSecretKey() []byte {
+
+	return Secretkey
+}
+
+func GetConfigSessionTTL() time.Duration {
+
+	return ServerConfig.SessionTTL
+}
+
+func GetConfigAccessTTL() time.Duration {
+
+	return ServerConfig.AccessTTL
+}
+
+func GetConfigRefreshTTL() time.Duration {
+
+	return ServerConfig.RefreshTTL
+}
+
+func GetConfigLoginFailures() int {
+
+	return ServerConfig.LoginFailures
+}
+
+func GetConfigLoginLockout() time.Duration {
+
+	return ServerConfig.LoginLockout
+}
+
+func GetConfigAttemptsWindow() time.Duration {
+
+	return ServerConfig.AttemptsWindow
+}
+
+func GetConfigAttemptsStore() string {
+
+	return ServerConfig.AttemptsStore
+}
+
+func GetConfigResetTTL() time.Duration {
+
+	return ServerConfig.ResetTTL
+}
+
+func GetConfigNotifier() string {
+
+	return ServerConfig.Notifier
+}
+
+func GetConfigNotifierPath() string {
+
+	return ServerConfig.NotifierPath
+}
+
+func GetConfigLoginMinLength() int {
+
+	return ServerConfig.LoginMinLength
+}
+
+func GetConfigLoginMaxLength() int {
+
+	return ServerConfig.LoginMaxLength
+}
+
+func GetConfigLoginPattern() string {
+
+	return ServerConfig.LoginPattern
+}
+
+func GetConfigPasswordMinLength() int {
+
+	return ServerConfig.PasswordMinLen
+}
+
+func GetConfigPasswordRequireDigit() bool {
+
+	return ServerConfig.PasswordDigit
+}
+
+func GetConfigPasswordRequireMixedCase() bool {
+
+	return ServerConfig.PasswordCase
+}
+
+func GetConfigPasswordRequireSymbol() bool {
+
+	return ServerConfig.PasswordSymbol
+}
+
+func GetConfigLogLevel() string {
+
+	return ServerConfig.LogLevel
+}
+
+func GetConfigLogFormat() string {
+
+	return ServerConfig.LogFormat
+}
+
+func GetConfigLogOutput() string {
+
+	return ServerConfig.LogOutput
+}
+
+func GetConfigTraceExporter() string {
+
+	return ServerConfig.TraceExporter
+}
+
+func GetConfigTraceEndpoint() string {
+
+	return ServerConfig.TraceEndpoint
+}
+
+func GetConfigReadyPollAge() time.Duration {
+
+	return ServerConfig.ReadyPollAge
+}
+
+func GetConfigAutoMigrate() bool {
 
-	return "logger.log"
+	return ServerConfig.AutoMigrate
 }
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"gofermart/internal/auth"
+	"gofermart/internal/logger"
+	"gofermart/internal/service"
+	"gofermart/internal/storage"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is the RFC 7807 error body returned by every handler.
+type Problem struct {
+	Type      string      `json:"type"`
+	Title     string      `json:"title"`
+	Status    int         `json:"status"`
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+type problemSpec struct {
+	status int
+	code   string
+}
+
+// problems maps domain errors to HTTP statuses. Errors not listed here are
+// answered with 500 and their text is not exposed.
+var problems = []struct {
+	err  error
+	spec problemSpec
+}{
+	{service.ErrMalformedRequest, problemSpec{http.StatusBadRequest, "malformed_request"}},
+	{service.ErrUnauthorized, problemSpec{http.StatusUnauthorized, "unauthorized"}},
+	{auth.ErrInvalidToken, problemSpec{http.StatusUnauthorized, "unauthorized"}},
+	{auth.ErrSessionExpired, problemSpec{http.StatusUnauthorized, "session_expired"}},
+	{service.ErrWrongCredentials, problemSpec{http.StatusUnauthorized, "wrong_credentials"}},
+	{service.ErrWrongPassword, problemSpec{http.StatusUnauthorized, "wrong_password"}},
+	{storage.ErrRefreshTokenNotFound, problemSpec{http.StatusUnauthorized, "invalid_refresh_token"}},
+	{storage.ErrRefreshTokenExpired, problemSpec{http.StatusUnauthorized, "invalid_refresh_token"}},
+	{storage.ErrRefreshTokenReused, problemSpec{http.StatusUnauthorized, "refresh_token_reused"}},
+	{storage.ErrResetTokenInvalid, problemSpec{http.StatusBadRequest, "invalid_reset_token"}},
+	{storage.ErrInsufficientFunds, problemSpec{http.StatusPaymentRequired, "insufficient_funds"}},
+	{service.ErrNotFound, problemSpec{http.StatusNotFound, "not_found"}},
+	{service.ErrLoginTaken, problemSpec{http.StatusConflict, "login_taken"}},
+	{service.ErrOrderTaken, problemSpec{http.StatusConflict, "order_taken"}},
+	{storage.ErrLoginExists, problemSpec{http.StatusConflict, "login_taken"}},
+	{storage.ErrOrderExists, problemSpec{http.StatusConflict, "order_taken"}},
+	{storage.ErrWithdrawalExists, problemSpec{http.StatusConflict, "withdrawal_exists"}},
+	{storage.ErrUserNotFound, problemSpec{http.StatusUnauthorized, "unauthorized"}},
+	{storage.ErrNegativeAmount, problemSpec{http.StatusUnprocessableEntity, "invalid_amount"}},
+	{service.ErrInvalidOrderNumber, problemSpec{http.StatusUnprocessableEntity, "invalid_order_number"}},
+	{service.ErrInvalidAmount, problemSpec{http.StatusUnprocessableEntity, "invalid_amount"}},
+	{service.ErrTooManyAttempts, problemSpec{http.StatusTooManyRequests, "too_many_attempts"}},
+	{storage.ErrConstraint, problemSpec{http.StatusConflict, "constraint_violation"}},
+}
+
+func newProblem(req *http.Request, err error) *Problem {
+	problem := &Problem{
+		Type:      "about:blank",
+		Status:    http.StatusInternalServerError,
+		Code:      "internal_error",
+		Message:   "internal server error",
+		RequestID: requestID(req),
+	}
+
+	var validation service.ValidationErrors
+	if errors.As(err, &validation) {
+		problem.Status = http.StatusBadRequest
+		problem.Code = "validation_failed"
+		problem.Message = "request validation failed"
+		problem.Details = validation
+	} else {
+		for _, p := range problems {
+			if errors.Is(err, p.err) {
+				problem.Status = p.spec.status
+				problem.Code = p.spec.code
+				problem.Message = err.Error()
+
+				break
+			}
+		}
+	}
+	problem.Title = http.StatusText(problem.Status)
+
+	return problem
+}
+
+// writeError renders err as application/problem+json.
+func writeError(res http.ResponseWriter, req *http.Request, err error) {
+	problem := newProblem(req, err)
+	if problem.Status >= http.StatusInternalServerError {
+		log := logger.FromContext(req.Context(), slog.Default())
+		log.Error("request failed", "method", req.Method, "path", req.URL.Path, "error", err)
+	}
+
+	p, _ := json.Marshal(problem)
+	res.Header().Set("Content-Type", problemContentType)
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+	res.WriteHeader(problem.Status)
+	res.Write(p)
+}
+
+func requestID(req *http.Request) string {
+	id, _ := req.Context().Value(requestIDKey{}).(string)
+
+	return id
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"gofermart/internal/storage"
+)
+
+const (
+	statusUp       = "up"
+	statusDegraded = "degraded"
+	statusDown     = "down"
+
+	pingTimeout = 2 * time.Second
+)
+
+// AccrualStatus is implemented by service.AccrualService.
+type AccrualStatus interface {
+	LastPoll() time.Time
+	ThrottledFor() time.Duration
+}
+
+type ComponentHealth struct {
+	Status  string                 `json:"status"`
+	Error   string                 `json:"error,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+type HealthResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentHealth `json:"components,omitempty"`
+}
+
+// Health serves the liveness and readiness probes.
+type Health struct {
+	repo       storage.Repository
+	accrual    AccrualStatus
+	maxPollAge time.Duration
+}
+
+func NewHealth(repo storage.Repository, accrual AccrualStatus, maxPollAge time.Duration) *Health {
+
+	return &Health{
+		repo:       repo,
+		accrual:    accrual,
+		maxPollAge: maxPollAge,
+	}
+}
+
+// LivenessAction answers as long as the process serves HTTP.
+func (h *Health) LivenessAction(res http.ResponseWriter, req *http.Request) {
+	writeHealth(res, HealthResponse{Status: statusUp})
+}
+
+// ReadinessAction checks the database pool and the accrual poller. Any
+// component down makes the instance not ready; a throttled poller is only
+// reported as degraded, because the instance still serves users.
+func (h *Health) ReadinessAction(res http.ResponseWriter, req *http.Request) {
+	components := map[string]ComponentHealth{
+		"database": h.database(req.Context()),
+		"accrual":  h.poller(),
+	}
+	response := HealthResponse{Status: statusUp, Components: components}
+	for _, component := range components {
+		if component.Status == statusDown {
+			response.Status = statusDown
+
+			break
+		}
+		if component.Status == statusDegraded {
+			response.Status = statusDegraded
+		}
+	}
+	writeHealth(res, response)
+}
+
+func (h *Health) database(ctx context.Context) ComponentHealth {
+	db, err := h.repo.SQLDB()
+	if err != nil {
+
+		return ComponentHealth{Status: statusDown, Error: err.Error()}
+	}
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	stats := db.Stats()
+	component := ComponentHealth{
+		Status: statusUp,
+		Details: map[string]interface{}{
+			"open_connections": stats.OpenConnections,
+			"in_use":           stats.InUse,
+			"idle":             stats.Idle,
+		},
+	}
+	if err := db.PingContext(ctx); err != nil {
+		component.Status = statusDown
+		component.Error = err.Error()
+	}
+
+	return component
+}
+
+func (h *Health) poller() ComponentHealth {
+	age := time.Since(h.accrual.LastPoll())
+	throttled := h.accrual.ThrottledFor()
+	component := ComponentHealth{
+		Status: statusUp,
+		Details: map[string]interface{}{
+			"last_poll_age_seconds": int64(age.Seconds()),
+			"throttled":             throttled > 0,
+		},
+	}
+	if throttled > 0 {
+		component.Status = statusDegraded
+		component.Details["throttled_for_seconds"] = int64(throttled.Seconds())
+	}
+	// while throttled the poller waits on purpose
+	if h.maxPollAge > 0 && age > h.maxPollAge && throttled == 0 {
+		component.Status = statusDown
+		component.Error = "accrual poller is stale"
+	}
+
+	return component
+}
+
+func writeHealth(res http.ResponseWriter, response HealthResponse) {
+	status := http.StatusOK
+	if response.Status == statusDown {
+		status = http.StatusServiceUnavailable
+	}
+
+	p, _ := json.Marshal(response)
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.Header().Set("Cache-Control", "no-store")
+	res.WriteHeader(status)
+	res.Write(p)
+}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"gofermart/internal/logger"
+	"gofermart/internal/metrics"
+)
+
+const (
+	RequestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+var tracer = otel.Tracer("gofermart/internal/handler")
+
+type requestIDKey struct{}
+
+type requestInfoKey struct{}
+
+// requestInfo collects facts learnt deeper in the chain, like the signed in
+// user, for the access log written on the way out.
+type requestInfo struct {
+	userID uint64
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+
+	return n, err
+}
+
+// RequestIDMiddleware takes the request ID from the X-Request-ID header or
+// generates one, echoes it in the response and puts it, together with a
+// logger carrying it, into the request context.
+func (h *Handler) RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+
+			log := h.logger.With("request_id", id)
+			if span := trace.SpanContextFromContext(r.Context()); span.IsValid() {
+				log = log.With("trace_id", span.TraceID().String())
+			}
+			ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+			ctx = logger.WithLogger(ctx, log)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		},
+	)
+}
+
+// AccessLogMiddleware writes one log line per request once it is served.
+func (h *Handler) AccessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			info := &requestInfo{}
+			sw := &statusWriter{ResponseWriter: w}
+
+			next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)))
+
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			args := []interface{}{
+				"method", r.Method,
+				"route", routePattern(r, r.URL.Path),
+				"status", status,
+				"bytes", sw.bytes,
+				"latency", time.Since(start),
+			}
+			if info.userID != 0 {
+				args = append(args, "user_id", info.userID)
+			}
+			h.requestLogger(r).Info("request served", args...)
+		},
+	)
+}
+
+// TracingMiddleware opens a server span per request, continuing the trace
+// the client sent in the W3C traceparent header. The span is named after the
+// chi route once it is known.
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			ctx, span := tracer.Start(ctx, r.Method,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", r.Method),
+					attribute.String("url.path", r.URL.Path),
+				))
+			defer span.End()
+			sw := &statusWriter{ResponseWriter: w}
+
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			route := routePattern(r, "unmatched")
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(
+				attribute.String("http.route", route),
+				attribute.Int("http.response.status_code", status),
+			)
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+		},
+	)
+}
+
+// MetricsMiddleware counts requests and their latency per chi route.
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+
+			next.ServeHTTP(sw, r)
+
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			metrics.ObserveHTTPRequest(r.Method, routePattern(r, "unmatched"), status, time.Since(start))
+		},
+	)
+}
+
+// setRequestUser records the signed in user for the access log.
+func setRequestUser(r *http.Request, userID uint64) {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		info.userID = userID
+	}
+}
+
+// routePattern returns the chi route that served r, or fallback when no
+// route matched.
+func routePattern(r *http.Request, fallback string) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil {
+		if pattern := rctx.RoutePattern(); pattern != "" {
+
+			return pattern
+		}
+	}
+
+	return fallback
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+
+		return "unknown"
+	}
+
+	return hex.EncodeToString(b)
+}
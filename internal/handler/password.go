@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gofermart/internal/auth"
+	"gofermart/internal/notify"
+	"gofermart/internal/service"
+)
+
+type PasswordForm struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type ResetRequestForm struct {
+	Login string `json:"login"`
+}
+
+type ResetConfirmForm struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// ChangePasswordAction replaces the password of the signed in user and logs
+// out all of their other sessions.
+func (h *Handler) ChangePasswordAction(res http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	user := auth.UserFromContext(req.Context())
+	session := auth.SessionFromContext(req.Context())
+
+	form := new(PasswordForm)
+	if err := decodeForm(req.Body, form); err != nil {
+		writeError(res, req, err) // 400 response
+
+		return
+	}
+	if errs := h.policy.ValidatePassword("new_password", form.NewPassword); len(errs) > 0 {
+		writeError(res, req, errs) // 400 response
+
+		return
+	}
+	// the current password is guessed like a login, so it is throttled alike
+	ip := clientIP(req)
+	wait, err := h.guard.Reserve(req.Context(), user.Login, ip)
+	if err != nil {
+		writeError(res, req, err) // 500 response
+
+		return
+	}
+	if wait > 0 {
+		tooManyAttempts(res, req, wait) // 429 response
+
+		return
+	}
+	if ok, _ := service.CheckPassword(user.Password, user.Login, form.CurrentPassword); !ok {
+		if err := h.guard.Fail(req.Context(), user.Login, ip); err != nil {
+			h.requestLogger(req).Error("login attempt saving failed", "error", err)
+		}
+		writeError(res, req, service.ErrWrongPassword) // 401 response
+
+		return
+	}
+	if err := h.guard.Succeed(req.Context(), user.Login, ip); err != nil {
+		h.requestLogger(req).Error("login attempts reset failed", "error", err)
+	}
+
+	if err := h.setPassword(req.Context(), user.ID, form.NewPassword); err != nil {
+		writeError(res, req, err) // 500 response
+
+		return
+	}
+	if err := h.sessions.RevokeAll(req.Context(), user.ID, session.ID); err != nil {
+		writeError(res, req, err) // 500 response
+
+		return
+	}
+	res.WriteHeader(http.StatusOK) // 200 response
+}
+
+// PasswordResetRequestAction sends a reset token to the user. It answers the
+// same way for unknown logins, so it cannot be used to probe accounts.
+func (h *Handler) PasswordResetRequestAction(res http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	form := new(ResetRequestForm)
+	if err := decodeForm(req.Body, form); err != nil {
+		writeError(res, req, err) // 400 response
+
+		return
+	}
+	form.Login = service.NormalizeLogin(form.Login)
+
+	user, err := h.storage.Repo.UserRegistered(req.Context(), form.Login)
+	if err != nil {
+		writeError(res, req, err) // 500 response
+
+		return
+	}
+	if user.ID != 0 {
+		token, expires, err := h.resets.Issue(req.Context(), user.ID)
+		if err != nil {
+			writeError(res, req, err) // 500 response
+
+			return
+		}
+		err = h.notifier.Notify(req.Context(), notify.Message{
+			UserID:    user.ID,
+			Recipient: user.Login,
+			Subject:   "Password reset",
+			Body: fmt.Sprintf("Use this token to reset your password: %s\nIt expires at %s.",
+				token, expires.Format(time.RFC3339)),
+		})
+		if err != nil {
+			writeError(res, req, err) // 500 response
+
+			return
+		}
+	}
+	res.WriteHeader(http.StatusAccepted) // 202 response
+}
+
+// PasswordResetConfirmAction sets a new password by a reset token and logs
+// the user out everywhere.
+func (h *Handler) PasswordResetConfirmAction(res http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	form := new(ResetConfirmForm)
+	if err := decodeForm(req.Body, form); err != nil {
+		writeError(res, req, err) // 400 response
+
+		return
+	}
+	errs := h.policy.ValidatePassword("new_password", form.NewPassword)
+	if form.Token == "" {
+		errs = append(service.ValidationErrors{{Field: "token", Message: "is required"}}, errs...)
+	}
+	if len(errs) > 0 {
+		writeError(res, req, errs) // 400 response
+
+		return
+	}
+
+	// hashed before the token is spent, so a failure leaves it usable
+	hash, err := service.HashPassword(form.NewPassword)
+	if err != nil {
+		writeError(res, req, err) // 500 response
+
+		return
+	}
+	userID, err := h.resets.Consume(req.Context(), form.Token, hash)
+	if err != nil {
+		writeError(res, req, err) // 400 or 500 response
+
+		return
+	}
+	if err := h.sessions.RevokeAll(req.Context(), userID, ""); err != nil {
+		writeError(res, req, err) // 500 response
+
+		return
+	}
+	if user := h.storage.Repo.GetUser(req.Context(), userID); user != nil {
+		_ = h.guard.Reset(req.Context(), user.Login)
+	}
+	res.WriteHeader(http.StatusOK) // 200 response
+}
+
+func (h *Handler) setPassword(ctx context.Context, userID uint64, password string) error {
+	hash, err := service.HashPassword(password)
+	if err != nil {
+
+		return err
+	}
+
+	return h.storage.Repo.UpdatePassword(ctx, userID, hash)
+}
+
+// tooManyAttempts answers a throttled password attempt.
+func tooManyAttempts(res http.ResponseWriter, req *http.Request, wait time.Duration) {
+	res.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeError(res, req, service.ErrTooManyAttempts)
+}
+
+func decodeForm(body io.Reader, form interface{}) error {
+	b, err := io.ReadAll(body)
+	if err != nil {
+
+		return err
+	}
+	if err := json.Unmarshal(b, form); err != nil {
+
+		return fmt.Errorf("%w: body is not valid JSON", service.ErrMalformedRequest)
+	}
+
+	return nil
+}
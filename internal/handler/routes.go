@@ -1,61 +1,102 @@
 package handler
 
 import (
-	"bufio"
 	"compress/gzip"
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 
-	"gofermart/internal/config"
+	"gofermart/internal/auth"
+	"gofermart/internal/logger"
+	"gofermart/internal/metrics"
 	"gofermart/internal/models"
+	"gofermart/internal/notify"
 	"gofermart/internal/service"
 	"gofermart/internal/storage"
 )
 
 type Handler struct {
-	storage storage.DB
+	storage  storage.DB
+	tokens   *auth.TokenManager
+	sessions *auth.Sessions
+	refresh  *auth.RefreshTokens
+	guard    *auth.LoginGuard
+	resets   *auth.PasswordResets
+	notifier notify.Notifier
+	policy   service.CredentialsPolicy
+	logger   *slog.Logger
 }
 
-func NewHandler(storage storage.DB) *Handler {
+func NewHandler(
+	storage storage.DB,
+	tokens *auth.TokenManager,
+	sessions *auth.Sessions,
+	refresh *auth.RefreshTokens,
+	guard *auth.LoginGuard,
+	resets *auth.PasswordResets,
+	notifier notify.Notifier,
+	logger *slog.Logger,
+) *Handler {
 
 	return &Handler{
-		storage: storage,
+		storage:  storage,
+		tokens:   tokens,
+		sessions: sessions,
+		refresh:  refresh,
+		guard:    guard,
+		resets:   resets,
+		notifier: notifier,
+		policy:   service.NewCredentialsPolicy(),
+		logger:   logger,
 	}
 }
 
+// requestLogger returns the logger of req. AuthMiddleware stores one carrying
+// the user ID in the request context; otherwise only the request ID is added.
+func (h *Handler) requestLogger(req *http.Request) *slog.Logger {
+	if log := logger.FromContext(req.Context(), nil); log != nil {
+
+		return log
+	}
+	log := h.logger
+	if id := requestID(req); id != "" {
+		log = log.With("request_id", id)
+	}
+
+	return log
+}
+
 type LoginForm struct {
 	Login    string `json:"login"`
 	Password string `json:"password"`
 }
 
 type Order struct {
-	Number   string  `json:"number"`
-	Status   string  `json:"status"`
-	Accrual  float64 `json:"accrual,omitempty"`
-	UploadAt string  `json:"uploaded_at"`
+	Number   string       `json:"number"`
+	Status   string       `json:"status"`
+	Accrual  models.Money `json:"accrual,omitempty"`
+	UploadAt string       `json:"uploaded_at"`
 }
 
 type Withdraw struct {
-	Order string  `json:"order"`
-	Sum   float64 `json:"sum"`
+	Order string       `json:"order"`
+	Sum   models.Money `json:"sum"`
 }
 
 type Processed struct {
-	Order    string  `json:"order"`
-	Sum      float64 `json:"sum"`
-	UploadAt string  `json:"processed_at"`
+	Order    string       `json:"order"`
+	Sum      models.Money `json:"sum"`
+	UploadAt string       `json:"processed_at"`
 }
 
 type Balance struct {
-	Current   float64 `json:"current"`
-	Withdrawn float64 `json:"withdrawn"`
+	Current   models.Money `json:"current"`
+	Withdrawn models.Money `json:"withdrawn"`
 }
 
 type gzipWriter struct {
@@ -63,65 +104,11 @@ type gzipWriter struct {
 	Writer io.Writer
 }
 
-type saver struct {
-	file   *os.File
-	writer *bufio.Writer
-}
-
-func NewSaver(filename string) (*saver, error) {
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0777)
-	if err != nil {
-
-		return nil, err
-	}
-
-	return &saver{
-		file:   file,
-		writer: bufio.NewWriter(file),
-	}, nil
-}
-
-func (p *saver) WriteShort(text string) error {
-	if _, err := p.writer.Write([]byte(text)); err != nil {
-
-		return err
-	}
-	if err := p.writer.WriteByte('\n'); err != nil {
-
-		return err
-	}
-
-	return p.writer.Flush()
-}
-
-func (p *saver) Close() error {
-
-	return p.file.Close()
-}
-
-var pathStorage = config.GetConfigPath()
-
 func (w gzipWriter) Write(b []byte) (int, error) {
 
 	return w.Writer.Write(b)
 }
 
-func ConnectionDBCheck() (int, string) {
-	db, err := sql.Open("pgx", config.GetConfigDBAddress())
-	if err != nil {
-
-		return 500, err.Error()
-	}
-	defer db.Close()
-	err = db.Ping()
-	if err != nil {
-
-		return 500, err.Error()
-	}
-
-	return 200, ""
-}
-
 func CodingMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
@@ -129,7 +116,7 @@ func CodingMiddleware(h http.Handler) http.Handler {
 			if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
 				gzw, err := gzip.NewWriterLevel(w, gzip.BestSpeed)
 				if err != nil {
-					http.Error(w, err.Error(), http.StatusBadRequest)
+					writeError(w, r, err)
 
 					return
 				}
@@ -144,7 +131,7 @@ func CodingMiddleware(h http.Handler) http.Handler {
 			if r.Header.Get("Content-Encoding") == "gzip" {
 				gzr, err := gzip.NewReader(r.Body)
 				if err != nil {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
+					writeError(w, r, fmt.Errorf("%w: body is not valid gzip", service.ErrMalformedRequest))
 
 					return
 				}
@@ -157,28 +144,46 @@ func CodingMiddleware(h http.Handler) http.Handler {
 	)
 }
 
-func AuthMiddleware(h http.Handler) http.Handler {
+// AuthMiddleware guards private routes: it verifies the session token once,
+// checks the server-side session, loads its user and puts both into the
+// request context. Only the server-side session slides as it is used; new
+// access tokens come from the refresh endpoint.
+func (h *Handler) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
-			if r.Method == http.MethodPost && r.ContentLength < 1 {
-				http.Error(w, "Empty body!", http.StatusBadRequest) // 400 response
-
-				// logger will be here
+			token := auth.TokenFromRequest(r)
+			if token == "" {
+				writeError(w, r, service.ErrUnauthorized) // 401 response
 
 				return
 			}
-			if r.Method == http.MethodGet {
-				cookie, _ := r.Cookie("user")
-				if cookie == nil {
-					http.Error(w, "Unauthorized!", http.StatusUnauthorized) // 401 response
+			claims, err := h.tokens.Verify(token)
+			if err != nil {
+				writeError(w, r, err) // 401 response
 
-					// logger will be here
+				return
+			}
+			session, err := h.sessions.Get(r.Context(), claims.SessionID)
+			if err != nil || session.UserID != claims.UserID {
+				writeError(w, r, auth.ErrSessionExpired) // 401 response
 
-					return
-				}
+				return
+			}
+			user := h.storage.Repo.GetUser(r.Context(), claims.UserID)
+			if user == nil {
+				writeError(w, r, service.ErrUnauthorized) // 401 response
 
+				return
 			}
-			h.ServeHTTP(w, r)
+			setRequestUser(r, user.ID)
+			log := h.requestLogger(r).With("user_id", user.ID, "session_id", session.ID)
+			if err := h.sessions.Touch(r.Context(), session); err != nil {
+				log.Warn("session renewal failed", "error", err)
+			}
+			ctx := auth.WithUser(r.Context(), user)
+			ctx = auth.WithSession(ctx, session)
+			ctx = logger.WithLogger(ctx, log)
+			next.ServeHTTP(w, r.WithContext(ctx))
 		},
 	)
 }
@@ -186,184 +191,241 @@ func AuthMiddleware(h http.Handler) http.Handler {
 func (h *Handler) RegisterAction(res http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 
-	saver, _ := NewSaver(pathStorage)
-	defer saver.Close()
+	log := h.requestLogger(req)
 
-	b, err := io.ReadAll(req.Body)
+	form := new(LoginForm)
+	if err := decodeForm(req.Body, form); err != nil {
+		log.Info("unmarshal failed", "error", err)
+
+		writeError(res, req, err) // 400 response
+
+		return
+	}
+
+	form.Login = service.NormalizeLogin(form.Login)
+	if errs := h.policy.ValidateRegistration(form.Login, form.Password); len(errs) > 0 {
+		writeError(res, req, errs) // 400 response
+
+		return
+	}
+
+	model, err := h.storage.Repo.UserRegistered(req.Context(), form.Login)
 	if err != nil {
 
-		_ = saver.WriteShort(fmt.Sprintf("%s - ReadAll body failed: %s", time.Now().String(), err.Error()))
+		log.Error("user lookup failed", "login", form.Login, "error", err)
 
-		http.Error(res, err.Error(), http.StatusInternalServerError) // 500 response
+		writeError(res, req, err) // 500 response
 
 		return
 	}
+	if model.ID != 0 {
 
-	form := new(LoginForm)
-	if err := json.Unmarshal(b, &form); err != nil {
-		_ = saver.WriteShort(fmt.Sprintf("%s - Unmarshal failed: %s", time.Now().String(), err.Error()))
+		log.Info("login already exists", "login", form.Login)
 
-		http.Error(res, err.Error(), http.StatusBadRequest) // 400 response
+		writeError(res, req, service.ErrLoginTaken) // 409 response
 
 		return
 	}
 
-	if model := h.storage.Repo.UserRegistered(form.Login); model.ID != 0 {
+	hash, err := service.HashPassword(form.Password)
+	if err != nil {
 
-		_ = saver.WriteShort(fmt.Sprintf("%s - Login %s already exist!", time.Now().String(), form.Login))
+		log.Error("password hashing failed", "error", err)
 
-		http.Error(res, "Login already exist!", http.StatusConflict) // 409 response
+		writeError(res, req, err) // 500 response
 
 		return
 	}
 
-	cookieValue := service.SetCookieValue(form.Login, form.Password)
 	user := models.User{}
 	user.Login = form.Login
-	user.Password = cookieValue
+	user.Password = hash
 	user.CreatedAt = time.Now()
-	if err := h.storage.Repo.RegisterUser(&user); err != nil {
-		errMessage := fmt.Sprintf("Model saving repository failed %s", err.Error())
-		http.Error(res, errMessage, http.StatusConflict) // 409 response
+	if err := h.storage.Repo.RegisterUser(req.Context(), &user); err != nil {
+		if errors.Is(err, storage.ErrLoginExists) {
+
+			log.Info("login already exists", "login", form.Login)
+		} else {
+
+			log.Error("user saving failed", "login", form.Login, "error", err)
+		}
+
+		writeError(res, req, err) // 409 or 500 response
 
 		return
 	}
 
-	http.SetCookie(res, service.SetUserCookie(req, cookieValue))
+	tokens, err := h.startSession(res, req, user.ID)
+	if err != nil {
+		writeError(res, req, err) // 500 response
+
+		return
+	}
+	p, _ := json.Marshal(tokens)
 	res.Header().Set("Content-Type", "application/json; charset=utf-8")
 	res.WriteHeader(http.StatusOK)
+	res.Write(p)
 }
 
 func (h *Handler) LoginAction(res http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 
-	saver, _ := NewSaver(pathStorage)
-	defer saver.Close()
+	log := h.requestLogger(req)
 
-	b, err := io.ReadAll(req.Body)
-	if err != nil {
+	form := new(LoginForm)
+	if err := decodeForm(req.Body, form); err != nil {
 
-		_ = saver.WriteShort(fmt.Sprintf("%s - ReadAll body failed: %s", time.Now().String(), err.Error()))
+		log.Info("unmarshal failed", "error", err)
 
-		http.Error(res, err.Error(), http.StatusInternalServerError) // 500 response
+		writeError(res, req, err) // 400 response
 
 		return
 	}
+	form.Login = service.NormalizeLogin(form.Login)
 
-	form := new(LoginForm)
-	if err := json.Unmarshal(b, &form); err != nil {
+	ip := clientIP(req)
+	wait, err := h.guard.Reserve(req.Context(), form.Login, ip)
+	if err != nil {
+		writeError(res, req, err) // 500 response
+
+		return
+	}
+	if wait > 0 {
 
-		_ = saver.WriteShort(fmt.Sprintf("%s - Unmarshal failed: %s", time.Now().String(), err.Error()))
+		log.Warn("too many login attempts", "login", form.Login, "ip", ip, "retry_after", wait)
 
-		http.Error(res, err.Error(), http.StatusBadRequest) // 400 response
+		tooManyAttempts(res, req, wait) // 429 response
 
 		return
 	}
 
-	passValue := ""
-	cookie, _ := req.Cookie("user")
-	if cookie == nil {
-		passValue = service.SetCookieValue(form.Login, form.Password)
-	} else {
-		passValue = cookie.Value
-	}
+	user, err := h.storage.Repo.UserRegistered(req.Context(), form.Login)
+	if err != nil {
+
+		log.Error("user lookup failed", "login", form.Login, "error", err)
+
+		writeError(res, req, err) // 500 response
 
-	if model := h.storage.Repo.LoginUser(form.Login, passValue); model.ID != 0 {
-		http.SetCookie(res, service.SetUserCookie(req, passValue))
-		res.Header().Set("Content-Type", "application/json; charset=utf-8")
-		res.WriteHeader(http.StatusOK)
-	} else {
+		return
+	}
+	ok, rehash := false, false
+	if user.ID != 0 {
+		ok, rehash = service.CheckPassword(user.Password, user.Login, form.Password)
+	}
+	if !ok {
+		if err := h.guard.Fail(req.Context(), form.Login, ip); err != nil {
+			log.Error("login attempt saving failed", "error", err)
+		}
 
-		_ = saver.WriteShort(fmt.Sprintf("%s - Wrong login/password!", time.Now().String()))
+		log.Info("wrong login or password", "login", form.Login, "ip", ip)
 
-		http.Error(res, "Wrong login/password!", http.StatusUnauthorized) // 401 response
+		writeError(res, req, service.ErrWrongCredentials) // 401 response
 
 		return
 	}
-}
+	if err := h.guard.Succeed(req.Context(), form.Login, ip); err != nil {
+		log.Error("login attempts reset failed", "error", err)
+	}
+	if rehash {
+		if hash, err := service.HashPassword(form.Password); err == nil {
+			if err := h.storage.Repo.UpdatePassword(req.Context(), user.ID, hash); err != nil {
+				log.Error("password rehash failed", "user_id", user.ID, "error", err)
+			}
+		}
+	}
 
-func (h *Handler) PostOrdresAction(res http.ResponseWriter, req *http.Request) {
-	if req.Method != http.MethodPost {
-		http.Error(res, "Only Post requests are allowed for this route!", http.StatusBadRequest)
+	tokens, err := h.startSession(res, req, user.ID)
+	if err != nil {
+		writeError(res, req, err) // 500 response
 
 		return
 	}
+	p, _ := json.Marshal(tokens)
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.WriteHeader(http.StatusOK)
+	res.Write(p)
+}
+
+func (h *Handler) PostOrdresAction(res http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 
-	saver, _ := NewSaver(pathStorage)
-	defer saver.Close()
+	log := h.requestLogger(req)
 
 	b, err := io.ReadAll(req.Body)
 	if err != nil {
 
-		_ = saver.WriteShort(fmt.Sprintf("%s - ReadAll body failed: %s", time.Now().String(), err.Error()))
+		log.Error("reading body failed", "error", err)
 
-		http.Error(res, err.Error(), http.StatusInternalServerError) // 500 response
+		writeError(res, req, err) // 500 response
 
 		return
 	}
-	cookie, _ := req.Cookie("user")
-	if cookie == nil {
-		http.Error(res, "Unauthorized!", http.StatusUnauthorized) // 401 response
-
-		// logger will be here
+	if len(b) == 0 {
+		writeError(res, req, fmt.Errorf("%w: empty body", service.ErrMalformedRequest)) // 400 response
 
 		return
 	}
-	luhn, _ := strconv.Atoi(string(b))
-	if !service.LuhnValid(luhn) {
+	user := auth.UserFromContext(req.Context())
+	number, err := models.ParseOrderNumber(string(b))
+	if err != nil {
 
-		_ = saver.WriteShort(fmt.Sprintf("%s - Wrong order number!", time.Now().String()))
+		log.Info("wrong order number", "order", string(b))
 
-		http.Error(res, "Wrong order number!", http.StatusUnprocessableEntity) // 422 response
+		writeError(res, req, service.ErrInvalidOrderNumber) // 422 response
 
 		return
 	}
-	user := h.storage.Repo.GetUser(cookie.Value)
-	if order := h.storage.Repo.GetOrder(luhn); order.ID != 0 {
-		if order.UserID == user.ID {
+	existing, err := h.storage.Repo.GetOrder(req.Context(), number)
+	if err != nil {
+		writeError(res, req, err) // 500 response
+
+		return
+	}
+	if existing.ID != 0 {
+		if existing.UserID != user.ID {
 
-			_ = saver.WriteShort(fmt.Sprintf("%s - Order already uploaded!", time.Now().String()))
+			log.Info("order uploaded by another user", "order", number)
 
-			http.Error(res, "Order already uploaded!", http.StatusOK) // 200 response
+			writeError(res, req, service.ErrOrderTaken) // 409 response
 
 			return
-		} else {
+		}
+		res.WriteHeader(http.StatusOK) // 200 response
 
-			_ = saver.WriteShort(fmt.Sprintf("%s - Order already uploaded by another user!", time.Now().String()))
+		return
+	}
 
-			http.Error(res, "Order already uploaded by another user!", http.StatusConflict) // 409 response
+	order := models.Order{}
+	order.UserID = user.ID
+	order.OrderNumber = number
+	order.Status = "NEW"
+	order.Accrual = 0
+	order.CreatedAt = time.Now()
+	order.UpdatedAt = time.Now()
+	if err := h.storage.Repo.SetOrder(req.Context(), &order); err != nil {
+		// another request stored the same number in between
+		if errors.Is(err, storage.ErrOrderExists) {
+			if existing, err := h.storage.Repo.GetOrder(req.Context(), number); err == nil && existing.UserID == user.ID {
+				res.WriteHeader(http.StatusOK) // 200 response
 
-			return
+				return
+			}
 		}
-	} else {
-		order := models.Order{}
-		order.UserID = user.ID
-		order.OrderNumber = luhn
-		order.Status = "NEW"
-		order.Accrual = 0
-		order.CreatedAt = time.Now()
-		order.UpdatedAt = time.Now()
-
-		h.storage.Repo.SetOrder(&order)
-		res.WriteHeader(http.StatusAccepted) // 202 response
+		writeError(res, req, err) // 409 or 500 response
+
+		return
 	}
+	res.WriteHeader(http.StatusAccepted) // 202 response
 }
 
 func (h *Handler) GetOrdresAction(res http.ResponseWriter, req *http.Request) {
-	cookie, _ := req.Cookie("user")
-	user := h.storage.Repo.GetUser(cookie.Value)
-	if user == nil {
-		http.Error(res, "User not founded!", http.StatusInternalServerError) // 500 response
-
-		return
-	}
+	user := auth.UserFromContext(req.Context())
 
 	orders := []Order{}
-	list := h.storage.Repo.GetOrders(user.ID)
+	list := h.storage.Repo.GetOrders(req.Context(), user.ID)
 	for _, obj := range list {
 		order := new(Order)
-		order.Number = strconv.Itoa(obj.OrderNumber)
+		order.Number = obj.OrderNumber.String()
 		order.Status = obj.Status
 		order.Accrual = obj.Accrual
 
@@ -371,7 +433,7 @@ func (h *Handler) GetOrdresAction(res http.ResponseWriter, req *http.Request) {
 		orders = append(orders, *order)
 	}
 	if len(orders) == 0 {
-		http.Error(res, "No data!", http.StatusNoContent) // 204 response
+		res.WriteHeader(http.StatusNoContent) // 204 response
 
 		return
 	}
@@ -382,30 +444,18 @@ func (h *Handler) GetOrdresAction(res http.ResponseWriter, req *http.Request) {
 }
 
 func (h *Handler) BalanceAction(res http.ResponseWriter, req *http.Request) {
-	cookie, _ := req.Cookie("user")
-	user := h.storage.Repo.GetUser(cookie.Value)
-	if user == nil {
-		http.Error(res, "User not founded!", http.StatusInternalServerError) // 500 response
+	user := auth.UserFromContext(req.Context())
 
-		return
-	}
-
-	accrouls := 0.0
-	withdrawn := 0.0
-
-	orders := h.storage.Repo.GetOrders(user.ID)
-	for _, obj := range orders {
-		accrouls = accrouls + obj.Accrual
-	}
+	account := h.storage.Repo.GetAccount(req.Context(), user.ID)
+	if account == nil {
+		writeError(res, req, fmt.Errorf("account of user %d not loaded", user.ID)) // 500 response
 
-	withdraws := h.storage.Repo.GetWithdraws(user.ID)
-	for _, obj := range withdraws {
-		withdrawn = withdrawn + obj.Withdraw
+		return
 	}
 
 	balance := new(Balance)
-	balance.Current = accrouls - withdrawn
-	balance.Withdrawn = withdrawn
+	balance.Current = account.Current
+	balance.Withdrawn = account.Withdrawn
 
 	p, _ := json.Marshal(balance)
 	res.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -414,78 +464,61 @@ func (h *Handler) BalanceAction(res http.ResponseWriter, req *http.Request) {
 }
 
 func (h *Handler) WithdrawAction(res http.ResponseWriter, req *http.Request) {
-	cookie, _ := req.Cookie("user")
-
 	defer req.Body.Close()
-	b, err := io.ReadAll(req.Body)
-	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
-
-		return
-	}
 
 	withdraw := Withdraw{}
-	if err := json.Unmarshal(b, &withdraw); err != nil {
-		http.Error(res, err.Error(), http.StatusNotImplemented)
+	if err := decodeForm(req.Body, &withdraw); err != nil {
+		writeError(res, req, err) // 400 response
 
 		return
 	}
-	luhn, _ := strconv.Atoi(withdraw.Order)
-	if !service.LuhnValid(luhn) {
-		http.Error(res, "Wrong order number!", http.StatusUnprocessableEntity) // 422 response
+	number, err := models.ParseOrderNumber(withdraw.Order)
+	if err != nil {
+		writeError(res, req, service.ErrInvalidOrderNumber) // 422 response
 
 		return
 	}
-
-	if order := h.storage.Repo.GetOrder(luhn); order.ID != 0 {
-		if order.Accrual < withdraw.Sum {
-			http.Error(res, "Not enouth balance!", http.StatusPaymentRequired) // 402 response
-
-			return
-		}
-	}
-
-	user := h.storage.Repo.GetUser(cookie.Value)
-	if user == nil {
-		http.Error(res, "User not founded!", http.StatusInternalServerError) // 500 response
+	if withdraw.Sum <= 0 {
+		writeError(res, req, fmt.Errorf("%w: sum must be positive", service.ErrInvalidAmount)) // 422 response
 
 		return
 	}
+
+	user := auth.UserFromContext(req.Context())
 	balance := models.Balance{}
 	balance.UserID = user.ID
-	balance.OrderID = luhn
+	balance.OrderID = number
 	balance.Withdraw = withdraw.Sum
 	balance.CreatedAt = time.Now()
 	balance.UpdatedAt = time.Now()
-	if err := h.storage.Repo.SetWithdraw(&balance); err != nil {
-		http.Error(res, "User not founded!", http.StatusInternalServerError) // 500 response
+	if err := h.storage.Repo.Withdraw(req.Context(), &balance); err != nil {
+		if errors.Is(err, storage.ErrInsufficientFunds) {
+			metrics.ObserveWithdrawal("insufficient_funds", 0)
+		} else {
+			metrics.ObserveWithdrawal("error", 0)
+		}
+		writeError(res, req, err) // 402 or 500 response
 
 		return
 	}
+	metrics.ObserveWithdrawal("ok", withdraw.Sum.Float64())
 	res.Header().Set("Content-Type", "application/json; charset=utf-8")
 	res.WriteHeader(http.StatusOK) // 200 response
 }
 
 func (h *Handler) WithdrawalsAction(res http.ResponseWriter, req *http.Request) {
-	cookie, _ := req.Cookie("user")
-
-	user := h.storage.Repo.GetUser(cookie.Value)
-	if user == nil {
-		http.Error(res, "User not founded!", http.StatusInternalServerError) // 500 response
-
-		return
-	}
+	user := auth.UserFromContext(req.Context())
 	processes := []Processed{}
-	list := h.storage.Repo.GetWithdraws(user.ID)
+	list := h.storage.Repo.GetWithdraws(req.Context(), user.ID)
 	for _, obj := range list {
 		processed := new(Processed)
-		processed.Order = strconv.Itoa(obj.OrderID)
+		processed.Order = obj.OrderID.String()
 		processed.Sum = obj.Withdraw
 		processed.UploadAt = obj.UpdatedAt.Format(time.RFC3339)
 		processes = append(processes, *processed)
 	}
 	if len(processes) == 0 {
-		http.Error(res, "No data!", http.StatusNoContent) // 204 response
+		res.WriteHeader(http.StatusNoContent) // 204 response
 
 		return
 	}
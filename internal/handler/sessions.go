@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"gofermart/internal/auth"
+	"gofermart/internal/service"
+)
+
+type Session struct {
+	ID         string `json:"id"`
+	UserAgent  string `json:"user_agent"`
+	IP         string `json:"ip"`
+	CreatedAt  string `json:"created_at"`
+	LastSeenAt string `json:"last_seen_at"`
+	ExpiresAt  string `json:"expires_at"`
+	Current    bool   `json:"current"`
+}
+
+func (h *Handler) LogoutAction(res http.ResponseWriter, req *http.Request) {
+	session := auth.SessionFromContext(req.Context())
+	if err := h.sessions.Revoke(req.Context(), session.ID); err != nil {
+		writeError(res, req, err) // 500 response
+
+		return
+	}
+	clearToken(res)
+	res.WriteHeader(http.StatusOK) // 200 response
+}
+
+func (h *Handler) LogoutAllAction(res http.ResponseWriter, req *http.Request) {
+	user := auth.UserFromContext(req.Context())
+	if err := h.sessions.RevokeAll(req.Context(), user.ID, ""); err != nil {
+		writeError(res, req, err) // 500 response
+
+		return
+	}
+	clearToken(res)
+	res.WriteHeader(http.StatusOK) // 200 response
+}
+
+func (h *Handler) SessionsAction(res http.ResponseWriter, req *http.Request) {
+	user := auth.UserFromContext(req.Context())
+	current := auth.SessionFromContext(req.Context())
+
+	sessions := []Session{}
+	for _, obj := range h.sessions.List(req.Context(), user.ID) {
+		session := new(Session)
+		session.ID = obj.ID
+		session.UserAgent = obj.UserAgent
+		session.IP = obj.IP
+		session.CreatedAt = obj.CreatedAt.Format(time.RFC3339)
+		session.LastSeenAt = obj.LastSeenAt.Format(time.RFC3339)
+		session.ExpiresAt = obj.ExpiresAt.Format(time.RFC3339)
+		session.Current = obj.ID == current.ID
+		sessions = append(sessions, *session)
+	}
+
+	p, _ := json.Marshal(sessions)
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.WriteHeader(http.StatusOK) // 200 response
+	res.Write(p)
+}
+
+func (h *Handler) RevokeSessionAction(res http.ResponseWriter, req *http.Request) {
+	user := auth.UserFromContext(req.Context())
+	id := chi.URLParam(req, "id")
+
+	session, err := h.sessions.Get(req.Context(), id)
+	if err != nil || session.UserID != user.ID {
+		writeError(res, req, fmt.Errorf("%w: session %s", service.ErrNotFound, id)) // 404 response
+
+		return
+	}
+	if err := h.sessions.Revoke(req.Context(), session.ID); err != nil {
+		writeError(res, req, err) // 500 response
+
+		return
+	}
+	res.WriteHeader(http.StatusOK) // 200 response
+}
+
+func clearToken(res http.ResponseWriter) {
+	http.SetCookie(res, &http.Cookie{
+		Name:     auth.CookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+	http.SetCookie(res, &http.Cookie{
+		Name:     auth.RefreshCookieName,
+		Value:    "",
+		Path:     "/api/user/token",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+}
+
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+
+		return req.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"gofermart/internal/auth"
+	"gofermart/internal/service"
+	"gofermart/internal/storage"
+)
+
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type RefreshForm struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// startSession opens a server-side session for the user, hands its access
+// and refresh tokens to the client as cookies and returns them for the body.
+func (h *Handler) startSession(res http.ResponseWriter, req *http.Request, userID uint64) (*TokenResponse, error) {
+	setRequestUser(req, userID)
+	session, err := h.sessions.Start(req.Context(), userID, req.UserAgent(), clientIP(req))
+	if err != nil {
+
+		return nil, err
+	}
+	token, expires, err := h.tokens.Issue(userID, session.ID, session.ExpiresAt)
+	if err != nil {
+
+		return nil, err
+	}
+	refresh, refreshExpires, err := h.refresh.Issue(req.Context(), userID, session.ID)
+	if err != nil {
+
+		return nil, err
+	}
+	setToken(res, req, token, expires)
+	setRefreshToken(res, refresh, refreshExpires)
+
+	return newTokenResponse(token, expires, refresh), nil
+}
+
+// TokenRefreshAction exchanges a refresh token for a new access token and a
+// new refresh token. A refresh token presented twice kills its session.
+func (h *Handler) TokenRefreshAction(res http.ResponseWriter, req *http.Request) {
+	defer req.Body.Close()
+
+	form := new(RefreshForm)
+	if b, err := io.ReadAll(req.Body); err == nil && len(b) > 0 {
+		if err := json.Unmarshal(b, form); err != nil {
+			writeError(res, req, fmt.Errorf("%w: body is not valid JSON", service.ErrMalformedRequest)) // 400 response
+
+			return
+		}
+	}
+	if form.RefreshToken == "" {
+		if cookie, err := req.Cookie(auth.RefreshCookieName); err == nil {
+			form.RefreshToken = cookie.Value
+		}
+	}
+	if form.RefreshToken == "" {
+		writeError(res, req, fmt.Errorf("%w: refresh token required", service.ErrMalformedRequest)) // 400 response
+
+		return
+	}
+
+	refresh, token, err := h.refresh.Rotate(req.Context(), form.RefreshToken)
+	if err != nil {
+		if errors.Is(err, storage.ErrRefreshTokenReused) {
+			_ = h.sessions.Revoke(req.Context(), token.SessionID)
+		}
+		if errors.Is(err, storage.ErrRefreshTokenReused) ||
+			errors.Is(err, storage.ErrRefreshTokenNotFound) ||
+			errors.Is(err, storage.ErrRefreshTokenExpired) {
+			clearToken(res)
+		}
+		writeError(res, req, err) // 401 or 500 response
+
+		return
+	}
+
+	session, err := h.sessions.Get(req.Context(), token.SessionID)
+	if err != nil {
+		clearToken(res)
+		writeError(res, req, err) // 401 response
+
+		return
+	}
+	setRequestUser(req, session.UserID)
+	_ = h.sessions.Touch(req.Context(), session)
+
+	access, expires, err := h.tokens.Issue(session.UserID, session.ID, session.ExpiresAt)
+	if err != nil {
+		writeError(res, req, err) // 500 response
+
+		return
+	}
+	setToken(res, req, access, expires)
+	setRefreshToken(res, refresh, token.ExpiresAt)
+
+	p, _ := json.Marshal(newTokenResponse(access, expires, refresh))
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.WriteHeader(http.StatusOK) // 200 response
+	res.Write(p)
+}
+
+func newTokenResponse(access string, expires time.Time, refresh string) *TokenResponse {
+
+	return &TokenResponse{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(expires).Seconds()),
+		RefreshToken: refresh,
+	}
+}
+
+// setToken hands the access token to the client both as a cookie and as an
+// Authorization header.
+func setToken(res http.ResponseWriter, req *http.Request, token string, expires time.Time) {
+	http.SetCookie(res, service.SetUserCookie(req, token, expires))
+	res.Header().Set("Authorization", "Bearer "+token)
+}
+
+func setRefreshToken(res http.ResponseWriter, token string, expires time.Time) {
+	http.SetCookie(res, &http.Cookie{
+		Name:     auth.RefreshCookieName,
+		Value:    token,
+		Path:     "/api/user/token",
+		Expires:  expires,
+		HttpOnly: true,
+	})
+}
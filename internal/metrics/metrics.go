@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"database/sql"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gophermart"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests served, by method, chi route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by method and chi route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	accrualRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "accrual_requests_total",
+		Help:      "Requests to the accrual system, by outcome.",
+	}, []string{"result"})
+
+	accrualDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "accrual_request_duration_seconds",
+		Help:      "Accrual system request latency, by outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result"})
+
+	accrualThrottles = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "accrual_throttles_total",
+		Help:      "429 responses received from the accrual system.",
+	})
+
+	withdrawals = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "withdrawals_total",
+		Help:      "Withdrawal requests, by result.",
+	}, []string{"result"})
+
+	withdrawnSum = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "withdrawals_sum",
+		Help:      "Sum of successful withdrawals in points.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		accrualRequests,
+		accrualDuration,
+		accrualThrottles,
+		withdrawals,
+		withdrawnSum,
+	)
+}
+
+// Handler serves the registered metrics in the Prometheus text format.
+func Handler() http.Handler {
+
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDB exports the connection pool stats of db.
+func RegisterDB(db *sql.DB) {
+	registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// RegisterQueueDepth exposes the pending order count, read on every scrape.
+// A failed read is reported as NaN rather than as an empty queue.
+func RegisterQueueDepth(depth func() (int64, error)) {
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "accrual_queue_depth",
+		Help:      "Orders in a pending status.",
+	}, func() float64 {
+		count, err := depth()
+		if err != nil {
+
+			return math.NaN()
+		}
+
+		return float64(count)
+	}))
+}
+
+func ObserveHTTPRequest(method string, route string, status int, elapsed time.Duration) {
+	httpRequests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(method, route).Observe(elapsed.Seconds())
+}
+
+// ObserveAccrualRequest records one accrual system call. result is one of
+// "ok", "not_registered", "throttled", "internal_error" or "error".
+func ObserveAccrualRequest(result string, elapsed time.Duration) {
+	accrualRequests.WithLabelValues(result).Inc()
+	accrualDuration.WithLabelValues(result).Observe(elapsed.Seconds())
+	if result == "throttled" {
+		accrualThrottles.Inc()
+	}
+}
+
+// ObserveWithdrawal records a withdrawal attempt; sum counts only when it
+// succeeded.
+func ObserveWithdrawal(result string, sum float64) {
+	withdrawals.WithLabelValues(result).Inc()
+	if result == "ok" {
+		withdrawnSum.Add(sum)
+	}
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+type Account struct {
+	UserID    uint64    `gorm:"primary_key;autoIncrement:false" json:"user_id"`
+	Current   Money     `gorm:"type:bigint;default:0;not null" json:"current"`
+	Withdrawn Money     `gorm:"type:bigint;default:0;not null" json:"withdrawn"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
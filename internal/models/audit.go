@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+const AuditLoginLockout = "login_lockout"
+
+type AuditEvent struct {
+	ID        uint64    `gorm:"primary_key" json:"id"`
+	Kind      string    `gorm:"index:audit_kind;not null" json:"kind"`
+	UserID    uint64    `gorm:"index:audit_user_id" json:"user_id,omitempty"`
+	Subject   string    `json:"subject"`
+	IP        string    `json:"ip"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// LoginAttempt counts failed logins per key, such as "login:alice" or
+// "ip:10.0.0.1". A login in progress is counted until it succeeds.
+type LoginAttempt struct {
+	Key           string    `gorm:"primary_key;size:255" json:"key"`
+	Failures      int       `gorm:"default:0;not null" json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+	LockedUntil   time.Time `json:"locked_until"`
+}
@@ -3,10 +3,10 @@ package models
 import "time"
 
 type Balance struct {
-	ID        uint64    `gorm:"primary_key" json:"id"`
-	UserID    uint64    `gorm:"index:user_id;" json:"user_id"`
-	OrderID   int       `gorm:"index:order_id;unique" json:"order_id"`
-	Withdraw  float64   `gorm:"type:float;default:0;not null" json:"withdraw"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID        uint64      `gorm:"primary_key" json:"id"`
+	UserID    uint64      `gorm:"index:user_id;" json:"user_id"`
+	OrderID   OrderNumber `gorm:"type:text;index:order_id;unique;not null" json:"order_id"`
+	Withdraw  Money       `gorm:"type:bigint;default:0;not null" json:"withdraw"`
+	CreatedAt time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
 }
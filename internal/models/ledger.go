@@ -0,0 +1,56 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	EntryAccrual    = "accrual"
+	EntryWithdrawal = "withdrawal"
+	EntryReversal   = "reversal"
+	EntryAdjustment = "adjustment"
+
+	LedgerAccrualAccount    = "system:accrual"
+	LedgerWithdrawalAccount = "system:withdrawal"
+	LedgerAdjustmentAccount = "system:adjustment"
+)
+
+// JournalEntry is one balanced movement of points. Its postings always have
+// equal debit and credit totals.
+type JournalEntry struct {
+	ID             uint64      `gorm:"primary_key" json:"id"`
+	IdempotencyKey string      `gorm:"index:idempotency_key;unique;not null" json:"idempotency_key"`
+	Kind           string      `gorm:"not null" json:"kind"`
+	UserID         uint64      `gorm:"index:journal_user_id" json:"user_id"`
+	OrderNumber    OrderNumber `gorm:"type:text;index:journal_order_number" json:"order_number,omitempty"`
+	Description    string      `json:"description"`
+	CreatedAt      time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	Postings       []Posting   `gorm:"foreignKey:EntryID" json:"postings"`
+}
+
+type Posting struct {
+	ID        uint64    `gorm:"primary_key" json:"id"`
+	EntryID   uint64    `gorm:"index:entry_id;not null" json:"entry_id"`
+	Account   string    `gorm:"index:account;not null" json:"account"`
+	Debit     Money     `gorm:"type:bigint;default:0;not null" json:"debit"`
+	Credit    Money     `gorm:"type:bigint;default:0;not null" json:"credit"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// StatementLine is a journal entry as seen from a single user account.
+// Amount is positive when points were credited to the user.
+type StatementLine struct {
+	EntryID     uint64      `json:"entry_id"`
+	Kind        string      `json:"kind"`
+	OrderNumber OrderNumber `json:"order_number,omitempty"`
+	Description string      `json:"description"`
+	Amount      Money       `json:"amount"`
+	Balance     Money       `json:"balance"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+func UserLedgerAccount(userID uint64) string {
+
+	return fmt.Sprintf("user:%d", userID)
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount of loyalty points stored in hundredths, so that sums are
+// exact integers. On the wire it is rendered as a plain decimal number.
+type Money int64
+
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+func NewMoneyFromFloat(value float64) Money {
+
+	return Money(math.Round(value * 100))
+}
+
+// moneyRange reports whether value points fit in Money. float64(MaxInt64) is
+// 2^63, so both bounds are exclusive and MinInt64 is never produced.
+func moneyRange(value float64) bool {
+	hundredths := math.Round(value * 100)
+
+	return hundredths < float64(math.MaxInt64) && hundredths > float64(math.MinInt64)
+}
+
+// ParseMoney parses a decimal like "500", "500.5" or "-0.25". Digits beyond
+// the hundredths are rounded half away from zero.
+func ParseMoney(value string) (Money, error) {
+	value = strings.TrimSpace(value)
+	if strings.ContainsAny(value, "eE") {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || !moneyRange(f) {
+
+			return 0, ErrInvalidMoney
+		}
+
+		return NewMoneyFromFloat(f), nil
+	}
+
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(value, "-")
+	whole, fraction, _ := strings.Cut(value, ".")
+	if whole == "" || !digitsOnly(whole) || !digitsOnly(fraction) {
+
+		return 0, ErrInvalidMoney
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/100-1 {
+
+		return 0, ErrInvalidMoney
+	}
+	cents := int64(0)
+	for i := 0; i < 2; i++ {
+		cents *= 10
+		if i < len(fraction) {
+			cents += int64(fraction[i] - '0')
+		}
+	}
+	amount := units*100 + cents
+	if len(fraction) > 2 && fraction[2] >= '5' {
+		amount++
+	}
+	if negative {
+		amount = -amount
+	}
+
+	return Money(amount), nil
+}
+
+func (m Money) Float64() float64 {
+
+	return float64(m) / 100
+}
+
+func (m Money) String() string {
+	// the magnitude is unsigned, so MinInt64 is negated without overflow
+	amount := uint64(m)
+	sign := ""
+	if m < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	units, cents := amount/100, amount%100
+	switch {
+	case cents == 0:
+		return fmt.Sprintf("%s%d", sign, units)
+	case cents%10 == 0:
+		return fmt.Sprintf("%s%d.%d", sign, units, cents/10)
+	default:
+		return fmt.Sprintf("%s%d.%02d", sign, units, cents)
+	}
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	value := string(data)
+	if value == "null" {
+
+		return nil
+	}
+	amount, err := ParseMoney(value)
+	if err != nil {
+
+		return err
+	}
+	*m = amount
+
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+
+	return int64(m), nil
+}
+
+func (m *Money) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case nil:
+		*m = 0
+	case int64:
+		*m = Money(value)
+	case float64:
+		*m = NewMoneyFromFloat(value)
+	case []byte:
+		amount, err := strconv.ParseInt(string(value), 10, 64)
+		if err != nil {
+
+			return fmt.Errorf("money scan: %w", err)
+		}
+		*m = Money(amount)
+	case string:
+		amount, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+
+			return fmt.Errorf("money scan: %w", err)
+		}
+		*m = Money(amount)
+	default:
+
+		return fmt.Errorf("money scan: unsupported type %T", src)
+	}
+
+	return nil
+}
+
+func digitsOnly(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+
+			return false
+		}
+	}
+
+	return true
+}
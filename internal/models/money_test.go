@@ -0,0 +1,110 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Money
+		err   error
+	}{
+		{"500", 50000, nil},
+		{"500.5", 50050, nil},
+		{"-0.25", -25, nil},
+		{" 1.005 ", 101, nil},
+		{"0.004", 0, nil},
+		{"1e2", 10000, nil},
+		{"2.5E-1", 25, nil},
+		{"92233720368547757.99", 9223372036854775799, nil},
+		{"92233720368547758", 0, ErrInvalidMoney},
+		{"1e17", 0, ErrInvalidMoney},
+		{"-1e17", 0, ErrInvalidMoney},
+		{"1e30", 0, ErrInvalidMoney},
+		{"1e400", 0, ErrInvalidMoney},
+		{"NaN", 0, ErrInvalidMoney},
+		{"", 0, ErrInvalidMoney},
+		{".5", 0, ErrInvalidMoney},
+		{"1.2.3", 0, ErrInvalidMoney},
+		{"abc", 0, ErrInvalidMoney},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.value)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseMoney(%q) error = %v, want %v", tt.value, err, tt.err)
+
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{0, "0"},
+		{50000, "500"},
+		{50050, "500.5"},
+		{50005, "500.05"},
+		{-25, "-0.25"},
+		{-100, "-1"},
+		{math.MaxInt64, "92233720368547758.07"},
+		{math.MinInt64, "-92233720368547758.08"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Errorf("Money(%d).String() = %q, want %q", int64(tt.money), got, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	type form struct {
+		Sum Money `json:"sum"`
+	}
+	tests := []struct {
+		body string
+		want Money
+		out  string
+		fail bool
+	}{
+		{body: `{"sum":751}`, want: 75100, out: `{"sum":751}`},
+		{body: `{"sum":751.5}`, want: 75150, out: `{"sum":751.5}`},
+		{body: `{"sum":0.01}`, want: 1, out: `{"sum":0.01}`},
+		{body: `{"sum":-3.25}`, want: -325, out: `{"sum":-3.25}`},
+		{body: `{"sum":null}`, want: 0, out: `{"sum":0}`},
+		{body: `{"sum":1e19}`, fail: true},
+		{body: `{"sum":"5"}`, fail: true},
+	}
+	for _, tt := range tests {
+		got := form{}
+		err := json.Unmarshal([]byte(tt.body), &got)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) = %d, want an error", tt.body, got.Sum)
+			}
+
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unmarshal(%s) error = %v", tt.body, err)
+
+			continue
+		}
+		if got.Sum != tt.want {
+			t.Errorf("Unmarshal(%s) = %d, want %d", tt.body, got.Sum, tt.want)
+		}
+		out, err := json.Marshal(got)
+		if err != nil || string(out) != tt.out {
+			t.Errorf("Marshal(%d) = %s, %v, want %s", got.Sum, out, err, tt.out)
+		}
+	}
+}
@@ -3,11 +3,14 @@ package models
 import "time"
 
 type Order struct {
-	ID          uint64    `gorm:"primary_key" json:"id"`
-	UserID      uint64    `gorm:"index:user_id;" json:"user_id"`
-	OrderNumber int       `gorm:"index:order;unique" json:"order_number"`
-	Status      string    `gorm:"not null" json:"status"`
-	Accrual     float64   `gorm:"type:float;default:0;not null" json:"accrual"`
-	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID                    uint64      `gorm:"primary_key" json:"id"`
+	UserID                uint64      `gorm:"index:user_id;" json:"user_id"`
+	OrderNumber           OrderNumber `gorm:"type:text;index:order;unique;not null" json:"order_number"`
+	Status                string      `gorm:"not null" json:"status"`
+	Accrual               Money       `gorm:"type:bigint;default:0;not null" json:"accrual"`
+	PollAttempts          int         `gorm:"default:0;not null" json:"poll_attempts"`
+	NextPollAt            time.Time   `gorm:"index:next_poll_at" json:"next_poll_at"`
+	NotRegisteredAttempts int         `gorm:"default:0;not null" json:"not_registered_attempts"`
+	CreatedAt             time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt             time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
 }
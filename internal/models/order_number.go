@@ -0,0 +1,85 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// OrderNumber is an order number as the user typed it: a string of digits of
+// any length with a valid Luhn check digit. It is stored as text, so leading
+// zeros survive and long numbers do not overflow.
+type OrderNumber string
+
+var ErrInvalidOrderNumber = errors.New("invalid order number")
+
+// ParseOrderNumber accepts digits only, surrounding whitespace aside, and
+// checks the Luhn digit.
+func ParseOrderNumber(value string) (OrderNumber, error) {
+	number := OrderNumber(strings.TrimSpace(value))
+	if !number.Valid() {
+
+		return "", ErrInvalidOrderNumber
+	}
+
+	return number, nil
+}
+
+// Valid reports whether n is a string of digits, not all of them zeros,
+// passing the Luhn check.
+func (n OrderNumber) Valid() bool {
+	if n == "" || !digitsOnly(string(n)) || strings.Trim(string(n), "0") == "" {
+
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < len(n); i++ {
+		digit := int(n[len(n)-1-i] - '0')
+		if i%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+
+	return sum%10 == 0
+}
+
+func (n OrderNumber) String() string {
+
+	return string(n)
+}
+
+// Value stores an empty number as NULL, journal entries without an order
+// have none.
+func (n OrderNumber) Value() (driver.Value, error) {
+	if n == "" {
+
+		return nil, nil
+	}
+
+	return string(n), nil
+}
+
+func (n *OrderNumber) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case nil:
+		*n = ""
+	case string:
+		*n = OrderNumber(value)
+	case []byte:
+		*n = OrderNumber(value)
+	case int64:
+		*n = OrderNumber(strconv.FormatInt(value, 10))
+	default:
+
+		return fmt.Errorf("order number scan: unsupported type %T", src)
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseOrderNumber(t *testing.T) {
+	tests := []struct {
+		value string
+		want  OrderNumber
+		err   error
+	}{
+		{"79927398713", "79927398713", nil},
+		{"4561261212345467", "4561261212345467", nil},
+		{" 12345678903\n", "12345678903", nil},
+		{"0079927398713", "0079927398713", nil},
+		{"018", "018", nil},
+		{"12345678901234567890123456789012345678901234567895", "12345678901234567890123456789012345678901234567895", nil},
+		{"79927398710", "", ErrInvalidOrderNumber},
+		{"4561261212345464", "", ErrInvalidOrderNumber},
+		{"12345678901234567890123456789012345678901234567890", "", ErrInvalidOrderNumber},
+		{"0", "", ErrInvalidOrderNumber},
+		{"000", "", ErrInvalidOrderNumber},
+		{"", "", ErrInvalidOrderNumber},
+		{"   ", "", ErrInvalidOrderNumber},
+		{"abc", "", ErrInvalidOrderNumber},
+		{"7992739871a", "", ErrInvalidOrderNumber},
+		{"-79927398713", "", ErrInvalidOrderNumber},
+		{"7992 7398 713", "", ErrInvalidOrderNumber},
+		{"٧٩٩٢٧٣٩٨٧١٣", "", ErrInvalidOrderNumber},
+	}
+	for _, tt := range tests {
+		got, err := ParseOrderNumber(tt.value)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseOrderNumber(%q) error = %v, want %v", tt.value, err, tt.err)
+
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseOrderNumber(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
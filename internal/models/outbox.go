@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// OutboxMessage is a notification waiting to be delivered to a user by an
+// external sender.
+type OutboxMessage struct {
+	ID        uint64     `gorm:"primary_key" json:"id"`
+	UserID    uint64     `gorm:"index:outbox_user_id" json:"user_id"`
+	Recipient string     `gorm:"not null" json:"recipient"`
+	Subject   string     `gorm:"not null" json:"subject"`
+	Body      string     `gorm:"not null" json:"body"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	SentAt    *time.Time `gorm:"index:outbox_sent_at" json:"sent_at,omitempty"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type Session struct {
+	ID         string     `gorm:"primary_key;size:64" json:"id"`
+	UserID     uint64     `gorm:"index:session_user_id;not null" json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `gorm:"index:session_expires_at;not null" json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (s *Session) Active(now time.Time) bool {
+
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -5,6 +5,30 @@ import "time"
 type User struct {
 	ID        uint64    `gorm:"primary_key" json:"id"`
 	Login     string    `gorm:"index:login;unique" json:"login"`
-	Password  string    `gorm:"not null" json:"password"`
+	Password  string    `gorm:"not null" json:"-"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 }
+
+// RefreshToken is a single-use refresh token. Only its SHA-256 hash is stored.
+// Tokens rotated from the same login share a FamilyID.
+type RefreshToken struct {
+	ID        uint64     `gorm:"primary_key" json:"id"`
+	UserID    uint64     `gorm:"index:refresh_user_id;not null" json:"user_id"`
+	SessionID string     `gorm:"index:refresh_session_id;size:64;not null" json:"session_id"`
+	FamilyID  string     `gorm:"index:refresh_family_id;size:64;not null" json:"family_id"`
+	TokenHash string     `gorm:"index:refresh_token_hash;unique;size:64;not null" json:"-"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// PasswordReset is a single-use password reset token. Only its hash is stored.
+type PasswordReset struct {
+	ID        uint64     `gorm:"primary_key" json:"id"`
+	UserID    uint64     `gorm:"index:reset_user_id;not null" json:"user_id"`
+	TokenHash string     `gorm:"index:reset_token_hash;unique;size:64;not null" json:"-"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"gofermart/internal/models"
+)
+
+type Message struct {
+	UserID    uint64 `json:"user_id"`
+	Recipient string `json:"recipient"`
+	Subject   string `json:"subject"`
+	Body      string `json:"body"`
+}
+
+// Notifier delivers messages to users, for example password reset links.
+type Notifier interface {
+	Notify(ctx context.Context, message Message) error
+}
+
+type OutboxStore interface {
+	CreateOutboxMessage(ctx context.Context, m *models.OutboxMessage) error
+}
+
+// outboxNotifier stores messages in the outbox table for an external sender.
+type outboxNotifier struct {
+	store OutboxStore
+}
+
+func NewOutboxNotifier(store OutboxStore) Notifier {
+
+	return &outboxNotifier{
+		store: store,
+	}
+}
+
+func (n *outboxNotifier) Notify(ctx context.Context, message Message) error {
+
+	return n.store.CreateOutboxMessage(ctx, &models.OutboxMessage{
+		UserID:    message.UserID,
+		Recipient: message.Recipient,
+		Subject:   message.Subject,
+		Body:      message.Body,
+	})
+}
+
+// fileNotifier appends messages as JSON lines to a local file.
+type fileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileNotifier(path string) Notifier {
+
+	return &fileNotifier{
+		path: path,
+	}
+}
+
+func (n *fileNotifier) Notify(ctx context.Context, message Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	file, err := os.OpenFile(n.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+
+		return err
+	}
+	defer file.Close()
+
+	return json.NewEncoder(file).Encode(struct {
+		Message
+		CreatedAt time.Time `json:"created_at"`
+	}{message, time.Now()})
+}
@@ -2,99 +2,224 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/go-chi/chi"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 
+	"gofermart/internal/auth"
 	"gofermart/internal/config"
 	"gofermart/internal/handler"
+	"gofermart/internal/logger"
+	"gofermart/internal/metrics"
+	"gofermart/internal/notify"
 	"gofermart/internal/service"
 	"gofermart/internal/storage"
+	"gofermart/internal/tracing"
+)
+
+const (
+	accrualTimeout    = 5 * time.Second
+	queueDepthTimeout = 2 * time.Second
 )
 
 type App struct {
 	httpServer *http.Server
 	storage    *storage.DB
+	logger     *slog.Logger
+	tracer     *sdktrace.TracerProvider
 }
 
-func NewApp() *App {
-	config.SetConfig()
+// NewApp wires the application from config, which has to be loaded with
+// config.SetConfig first.
+func NewApp() (*App, error) {
+	log, err := newLogger()
+	if err != nil {
+
+		return nil, err
+	}
+	if len(config.GetConfigSecretKey()) == 0 {
+
+		return nil, errors.New("no token signing key: set -k or SECRET_KEY")
+	}
+
+	tracer, err := tracing.Setup(context.Background(), config.GetConfigTraceExporter(), config.GetConfigTraceEndpoint())
+	if err != nil {
 
-	if status, _ := handler.ConnectionDBCheck(); status != http.StatusOK {
+		return nil, fmt.Errorf("tracing configuration: %w", err)
+	}
 
-		return nil
+	db, err := storage.NewDB(log)
+	if err != nil {
+
+		return nil, fmt.Errorf("storage: %w", err)
+	}
+	if sqlDB, err := db.Repo.SQLDB(); err == nil {
+		metrics.RegisterDB(sqlDB)
 	}
+	metrics.RegisterQueueDepth(func() (int64, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), queueDepthTimeout)
+		defer cancel()
+
+		return db.Repo.CountPendingOrders(ctx)
+	})
 
 	return &App{
-		storage: storage.NewDB(),
+		storage: db,
+		logger:  log,
+		tracer:  tracer,
+	}, nil
+}
+
+func newLogger() (*slog.Logger, error) {
+	log, err := logger.New(config.GetConfigLogLevel(), config.GetConfigLogFormat(), config.GetConfigLogOutput())
+	if err != nil {
+
+		return nil, fmt.Errorf("logger configuration: %w", err)
 	}
+	slog.SetDefault(log)
+
+	return log, nil
 }
 
-func registerHTTPEndpoints(router *chi.Mux, storage storage.DB) {
-	h := handler.NewHandler(storage)
+func registerHTTPEndpoints(router *chi.Mux, storage storage.DB, log *slog.Logger, accrual *service.AccrualService) {
+	tokens := auth.NewTokenManager(config.GetConfigSecretKey(), config.GetConfigAccessTTL())
+	sessions := auth.NewSessions(storage.Repo, config.GetConfigSessionTTL())
+	refresh := auth.NewRefreshTokens(storage.Repo, config.GetConfigRefreshTTL())
+	guard := auth.NewLoginGuard(newAttemptStore(storage), storage.Repo,
+		auth.AttemptPolicy{
+			FreeAttempts: 3,
+			MaxFailures:  config.GetConfigLoginFailures(),
+			BaseDelay:    time.Second,
+			Lockout:      config.GetConfigLoginLockout(),
+			Window:       config.GetConfigAttemptsWindow(),
+		},
+		auth.AttemptPolicy{
+			FreeAttempts: 20,
+			MaxFailures:  10 * config.GetConfigLoginFailures(),
+			BaseDelay:    time.Second,
+			Lockout:      config.GetConfigLoginLockout(),
+			Window:       config.GetConfigAttemptsWindow(),
+		},
+	)
+	resets := auth.NewPasswordResets(storage.Repo, config.GetConfigResetTTL())
+	h := handler.NewHandler(storage, tokens, sessions, refresh, guard, resets, newNotifier(storage), log)
+
+	router.Use(handler.TracingMiddleware)
+	router.Use(h.RequestIDMiddleware)
+	router.Use(h.AccessLogMiddleware)
+	router.Use(handler.MetricsMiddleware)
+
+	router.Handle("/metrics", metrics.Handler())
+	health := handler.NewHealth(storage.Repo, accrual, config.GetConfigReadyPollAge())
+	router.Get("/healthz", health.LivenessAction)
+	router.Get("/readyz", health.ReadinessAction)
 
 	router.Route("/api", func(r chi.Router) {
 		r.Use(handler.CodingMiddleware)
 
 		r.Route("/user", func(r chi.Router) {
-			r.Use(handler.AuthMiddleware)
 			r.Post("/register", h.RegisterAction)
 			r.Post("/login", h.LoginAction)
-			r.Post("/orders", h.PostOrdresAction)
-			r.Get("/orders", h.GetOrdresAction)
-			r.Route("/balance", func(r chi.Router) {
-				r.Get("/", h.BalanceAction)
-				r.Post("/withdraw", h.WithdrawAction)
+			r.Post("/token/refresh", h.TokenRefreshAction)
+			r.Post("/password/reset", h.PasswordResetRequestAction)
+			r.Post("/password/reset/confirm", h.PasswordResetConfirmAction)
+
+			r.Group(func(r chi.Router) {
+				r.Use(h.AuthMiddleware)
+				r.Post("/orders", h.PostOrdresAction)
+				r.Get("/orders", h.GetOrdresAction)
+				r.Route("/balance", func(r chi.Router) {
+					r.Get("/", h.BalanceAction)
+					r.Post("/withdraw", h.WithdrawAction)
+				})
+				r.Get("/withdrawals", h.WithdrawalsAction)
+				r.Post("/password", h.ChangePasswordAction)
+				r.Post("/logout", h.LogoutAction)
+				r.Post("/logout/all", h.LogoutAllAction)
+				r.Get("/sessions", h.SessionsAction)
+				r.Delete("/sessions/{id}", h.RevokeSessionAction)
 			})
-			r.Get("/withdrawals", h.WithdrawalsAction)
 		})
 	})
 }
 
+func newAttemptStore(storage storage.DB) auth.AttemptStore {
+	if config.GetConfigAttemptsStore() == "memory" {
+
+		return auth.NewMemoryAttemptStore()
+	}
+
+	return storage.Repo
+}
+
+func newNotifier(storage storage.DB) notify.Notifier {
+	if config.GetConfigNotifier() == "file" {
+
+		return notify.NewFileNotifier(config.GetConfigNotifierPath())
+	}
+
+	return notify.NewOutboxNotifier(storage.Repo)
+}
+
 func (a *App) Run(ctx context.Context) error {
+	// a failed listener stops the poller as a cancelled ctx would
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
 	route := chi.NewRouter()
 	address := config.GetConfigServerAddress()
-	registerHTTPEndpoints(route, *a.storage)
+	client := service.NewHTTPAccrualClient(config.GetConfigAccrualAddress(), accrualTimeout)
+	accrual := service.NewAccrualService(a.storage, client, config.GetConfigAccrualWorkers(), config.GetConfigAccrualBatch(), config.GetConfigAccrualMaxAge(), a.logger)
+	registerHTTPEndpoints(route, *a.storage, a.logger, accrual)
 
 	a.httpServer = &http.Server{
 		Addr:    address,
 		Handler: route,
 	}
 
-	ticker := time.NewTicker(1 * time.Second)
-	tickerChan := make(chan bool)
-
-	go service.AccrualService(a.storage, ticker, tickerChan)
+	accrualDone := make(chan struct{})
+	go func() {
+		accrual.Run(ctx)
+		close(accrualDone)
+	}()
 
+	a.logger.Info("server started", "address", address)
+	serveErr := make(chan error, 1)
 	go func() {
 		if err := a.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to listen and serve: %+v", err)
+			serveErr <- fmt.Errorf("listen and serve: %w", err)
 		}
-
+		close(serveErr)
 	}()
 
-	<-ctx.Done()
+	var runErr error
+	select {
+	case <-ctx.Done():
+	case runErr = <-serveErr:
+		stop()
+	}
 
-	ctx, shutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownCtx, shutdown := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdown()
 
-	quit := make(chan struct{}, 1)
-	go func() {
-		time.Sleep(3 * time.Second)
-		ticker.Stop()
-		tickerChan <- true
-		quit <- struct{}{}
-	}()
+	if err := a.httpServer.Shutdown(shutdownCtx); err != nil {
+		return errors.Join(runErr, fmt.Errorf("server shutdown: %w", err))
+	}
 
 	select {
-	case <-ctx.Done():
-		return fmt.Errorf("server shutdown: %w", ctx.Err())
-	case <-quit:
-		log.Println("finished")
+	case <-shutdownCtx.Done():
+		return errors.Join(runErr, fmt.Errorf("server shutdown: %w", shutdownCtx.Err()))
+	case <-accrualDone:
+	}
+	if err := a.tracer.Shutdown(shutdownCtx); err != nil {
+		return errors.Join(runErr, fmt.Errorf("tracer shutdown: %w", err))
 	}
+	a.logger.Info("server stopped")
 
-	return nil
+	return runErr
 }
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"gofermart/internal/config"
+	"gofermart/internal/storage"
+)
+
+var errMigrateUsage = errors.New("usage: gophermart [flags] migrate up|down [steps]|status")
+
+// Migrate runs the "migrate" command: up applies pending migrations, down
+// rolls back the last one (or the given number of steps) and status lists
+// them all.
+func Migrate(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+
+		return errMigrateUsage
+	}
+	log, err := newLogger()
+	if err != nil {
+
+		return err
+	}
+	repo, err := storage.NewRepository(config.GetConfigDBAddress(), log, false)
+	if err != nil {
+
+		return err
+	}
+	db, err := repo.SQLDB()
+	if err != nil {
+
+		return err
+	}
+	defer db.Close()
+	migrator, err := storage.NewMigrator(db, log)
+	if err != nil {
+
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		fmt.Fprintf(out, "applied %d migration(s)\n", applied)
+
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+
+				return errMigrateUsage
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		fmt.Fprintf(out, "rolled back %d migration(s)\n", rolledBack)
+
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%04d %-30s %s\n", status.Version, status.Name, applied)
+		}
+
+		return nil
+	}
+
+	return errMigrateUsage
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"gofermart/internal/metrics"
+	"gofermart/internal/models"
+	"gofermart/internal/storage"
+)
+
+const (
+	pollInterval   = 1 * time.Second
+	pollLease      = 30 * time.Second
+	backoffBase    = 1 * time.Second
+	backoffMaximum = 5 * time.Minute
+)
+
+var tracer = otel.Tracer("gofermart/internal/service")
+
+// AccrualService polls the accrual system for pending orders. A dispatcher
+// claims due orders for the idle workers of a fixed pool, so a claimed order
+// never waits for a worker while its lease runs out.
+type AccrualService struct {
+	storage  *storage.DB
+	client   AccrualClient
+	throttle *accrualThrottle
+	workers  int
+	batch    int
+	maxAge   time.Duration
+	logger   *slog.Logger
+	// busy counts the workers holding an order, including orders handed
+	// over but not yet picked up
+	busy atomic.Int64
+	// lastPoll holds the unix nanoseconds of the last time the poller was
+	// known to be current: an answer from the accrual system or a claim that
+	// found nothing due
+	lastPoll atomic.Int64
+}
+
+func NewAccrualService(storage *storage.DB, client AccrualClient, workers int, batch int, maxAge time.Duration, logger *slog.Logger) *AccrualService {
+	if workers < 1 {
+		workers = 1
+	}
+	if batch < 1 {
+		batch = 1
+	}
+
+	s := &AccrualService{
+		storage:  storage,
+		client:   client,
+		throttle: newAccrualThrottle(),
+		workers:  workers,
+		batch:    batch,
+		maxAge:   maxAge,
+		logger:   logger.With("component", "accrual"),
+	}
+	s.markPolled()
+
+	return s
+}
+
+// LastPoll reports when the poller was last known to be up to date.
+func (s *AccrualService) LastPoll() time.Time {
+
+	return time.Unix(0, s.lastPoll.Load())
+}
+
+// ThrottledFor reports how long requests to the accrual system stay paused
+// after a 429 response; zero when they are not.
+func (s *AccrualService) ThrottledFor() time.Duration {
+
+	return s.throttle.remaining()
+}
+
+func (s *AccrualService) markPolled() {
+	s.lastPoll.Store(time.Now().UnixNano())
+}
+
+// Run blocks until ctx is cancelled and all workers have finished their
+// current order.
+func (s *AccrualService) Run(ctx context.Context) {
+	jobs := make(chan models.Order)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for order := range jobs {
+				s.process(ctx, order)
+				s.busy.Add(-1)
+			}
+		}()
+	}
+
+	s.dispatch(ctx, jobs)
+	close(jobs)
+	wg.Wait()
+}
+
+func (s *AccrualService) dispatch(ctx context.Context, jobs chan<- models.Order) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		// claims made during a 429 pause would only wait for it to end
+		if s.throttle.remaining() > 0 {
+			continue
+		}
+
+		for {
+			idle := s.workers - int(s.busy.Load())
+			if idle > s.batch {
+				idle = s.batch
+			}
+			if idle <= 0 {
+				break
+			}
+			// the lease also covers the wait for a slot under a per-minute limit
+			lease := pollLease + s.throttle.backlog(s.workers)
+			orders, err := s.storage.Repo.ClaimOrders(ctx, idle, lease)
+			if err != nil {
+				if ctx.Err() == nil {
+					s.logger.Error("claim orders failed", "error", err)
+				}
+
+				break
+			}
+			if len(orders) == 0 {
+				s.markPolled()
+			}
+			for _, order := range orders {
+				s.busy.Add(1)
+				select {
+				case <-ctx.Done():
+					s.busy.Add(-1)
+
+					return
+				case jobs <- order:
+				}
+			}
+			if len(orders) < idle {
+				break
+			}
+		}
+	}
+}
+
+func (s *AccrualService) process(ctx context.Context, order models.Order) {
+	if delay := s.throttle.delay(); delay > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+
+	ctx, span := tracer.Start(ctx, "accrual.process",
+		trace.WithAttributes(attribute.String("order.number", order.OrderNumber.String())))
+	defer span.End()
+	// the outcome is stored even when shutdown starts during the request
+	store := context.WithoutCancel(ctx)
+
+	start := time.Now()
+	accrual, err := s.client.GetOrder(ctx, order.OrderNumber)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		span.RecordError(err)
+		metrics.ObserveAccrualRequest(accrualResult(err), time.Since(start))
+		var throttled *TooManyRequestsError
+		if errors.As(err, &throttled) {
+			s.throttle.pause(throttled.RetryAfter, throttled.Limit)
+			s.reschedule(store, order.OrderNumber, order.PollAttempts, time.Now().Add(s.throttle.remaining()))
+
+			return
+		}
+		if errors.Is(err, ErrOrderNotRegistered) {
+			s.markPolled()
+			s.notRegistered(store, order)
+
+			return
+		}
+		s.logger.Warn("accrual request failed", "order", order.OrderNumber, "attempts", order.PollAttempts+1, "error", err)
+		attempts := order.PollAttempts + 1
+		s.reschedule(store, order.OrderNumber, attempts, time.Now().Add(backoff(attempts)))
+
+		return
+	}
+
+	metrics.ObserveAccrualRequest("ok", time.Since(start))
+	s.markPolled()
+
+	if accrual.Status != order.Status && accrual.Order == order.OrderNumber {
+		if err := s.storage.Repo.SetAccrual(store, order.OrderNumber, accrual.Status, accrual.Accrual); err != nil {
+			// a failed update, a ledger conflict among them, is retried with
+			// the same backoff as a failed request
+			s.logger.Error("set accrual failed", "order", order.OrderNumber, "status", accrual.Status, "error", err)
+			attempts := order.PollAttempts + 1
+			s.reschedule(store, order.OrderNumber, attempts, time.Now().Add(backoff(attempts)))
+
+			return
+		}
+	}
+	s.reschedule(store, order.OrderNumber, 0, time.Now().Add(pollInterval))
+}
+
+// notRegistered keeps an order unknown to the accrual system in NEW and gives
+// up on it once it is older than the configured max age.
+func (s *AccrualService) notRegistered(ctx context.Context, order models.Order) {
+	if s.maxAge > 0 && time.Since(order.CreatedAt) > s.maxAge {
+		if err := s.storage.Repo.SetAccrual(ctx, order.OrderNumber, "INVALID", 0); err != nil {
+			s.logger.Error("set accrual failed", "order", order.OrderNumber, "status", "INVALID", "error", err)
+		}
+
+		return
+	}
+
+	next := time.Now().Add(backoff(order.NotRegisteredAttempts + 1))
+	if err := s.storage.Repo.MarkOrderNotRegistered(ctx, order.OrderNumber, next); err != nil {
+		s.logger.Error("mark order not registered failed", "order", order.OrderNumber, "error", err)
+	}
+}
+
+func (s *AccrualService) reschedule(ctx context.Context, orderNumber models.OrderNumber, attempts int, next time.Time) {
+	if err := s.storage.Repo.RescheduleOrder(ctx, orderNumber, attempts, next); err != nil {
+		s.logger.Error("reschedule order failed", "order", orderNumber, "error", err)
+	}
+}
+
+func accrualResult(err error) string {
+	var throttled *TooManyRequestsError
+	switch {
+	case errors.As(err, &throttled):
+
+		return "throttled"
+	case errors.Is(err, ErrOrderNotRegistered):
+
+		return "not_registered"
+	case errors.Is(err, ErrAccrualInternal):
+
+		return "internal_error"
+	}
+
+	return "error"
+}
+
+func backoff(attempts int) time.Duration {
+	delay := backoffBase
+	for i := 1; i < attempts && delay < backoffMaximum; i++ {
+		delay *= 2
+	}
+	if delay > backoffMaximum {
+		delay = backoffMaximum
+	}
+
+	return delay
+}
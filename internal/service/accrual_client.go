@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"gofermart/internal/models"
+)
+
+var (
+	ErrOrderNotRegistered = errors.New("order is not registered in accrual system")
+	ErrAccrualInternal    = errors.New("accrual system internal error")
+)
+
+// TooManyRequestsError is returned when the accrual system answers 429.
+// Limit is zero when the response did not mention the per-minute limit.
+type TooManyRequestsError struct {
+	RetryAfter time.Duration
+	Limit      int
+}
+
+func (e *TooManyRequestsError) Error() string {
+
+	return fmt.Sprintf("accrual system throttled requests for %s", e.RetryAfter)
+}
+
+type AccrualClient interface {
+	GetOrder(ctx context.Context, number models.OrderNumber) (*Accrual, error)
+}
+
+type httpAccrualClient struct {
+	address string
+	client  *http.Client
+}
+
+func NewHTTPAccrualClient(address string, timeout time.Duration) AccrualClient {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConnsPerHost:   16,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	return &httpAccrualClient{
+		address: address,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+		},
+	}
+}
+
+func (c *httpAccrualClient) GetOrder(ctx context.Context, number models.OrderNumber) (*Accrual, error) {
+	ctx, span := tracer.Start(ctx, "GET /api/orders/{number}",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", http.MethodGet),
+			attribute.String("order.number", number.String()),
+		))
+	defer span.End()
+
+	accrualURL := fmt.Sprintf("%s/api/orders/%s", c.address, number)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, accrualURL, nil)
+	if err != nil {
+
+		return nil, fmt.Errorf("client could not create request: %w", err)
+	}
+	span.SetAttributes(attribute.String("url.full", accrualURL))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(request.Header))
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, fmt.Errorf("accrual request failed: %w", err)
+	}
+	defer response.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", response.StatusCode))
+	if response.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, response.Status)
+	}
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		accrual := Accrual{}
+		if err := json.NewDecoder(response.Body).Decode(&accrual); err != nil {
+
+			return nil, fmt.Errorf("accrual response decoding failed: %w", err)
+		}
+
+		return &accrual, nil
+	case http.StatusNoContent:
+
+		return nil, ErrOrderNotRegistered
+	case http.StatusTooManyRequests:
+		body, _ := io.ReadAll(response.Body)
+
+		return nil, &TooManyRequestsError{
+			RetryAfter: parseRetryAfter(response.Header.Get("Retry-After")),
+			Limit:      parseRequestLimit(body),
+		}
+	case http.StatusInternalServerError:
+
+		return nil, ErrAccrualInternal
+	default:
+
+		return nil, fmt.Errorf("accrual system responded with status %d", response.StatusCode)
+	}
+}
+
+// MemoryAccrualClient answers from a map and is meant for tests and local
+// runs without the accrual black box. Unknown orders are not registered.
+type MemoryAccrualClient struct {
+	mu       sync.RWMutex
+	accruals map[models.OrderNumber]Accrual
+	errors   map[models.OrderNumber]error
+}
+
+func NewMemoryAccrualClient() *MemoryAccrualClient {
+
+	return &MemoryAccrualClient{
+		accruals: map[models.OrderNumber]Accrual{},
+		errors:   map[models.OrderNumber]error{},
+	}
+}
+
+func (c *MemoryAccrualClient) SetOrder(number models.OrderNumber, accrual Accrual) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.accruals[number] = accrual
+	delete(c.errors, number)
+}
+
+// SetError makes every following request for the order fail with err.
+func (c *MemoryAccrualClient) SetError(number models.OrderNumber, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.errors[number] = err
+}
+
+func (c *MemoryAccrualClient) GetOrder(ctx context.Context, number models.OrderNumber) (*Accrual, error) {
+	if err := ctx.Err(); err != nil {
+
+		return nil, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if err, ok := c.errors[number]; ok {
+
+		return nil, err
+	}
+	accrual, ok := c.accruals[number]
+	if !ok {
+
+		return nil, ErrOrderNotRegistered
+	}
+
+	return &accrual, nil
+}
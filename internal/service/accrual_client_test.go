@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"gofermart/internal/tracing"
+)
+
+func TestHTTPAccrualClientTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewProvider(exporter)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	traceparent := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent <- r.Header.Get("traceparent")
+		if r.URL.Path != "/api/orders/12345678903" {
+			t.Errorf("request path = %q", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"order":"12345678903","status":"PROCESSED","accrual":500.5}`))
+	}))
+	defer server.Close()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "accrual.process")
+	accrual, err := NewHTTPAccrualClient(server.URL, time.Second).GetOrder(ctx, "12345678903")
+	parent.End()
+	if err != nil {
+		t.Fatalf("GetOrder: %v", err)
+	}
+	if accrual.Status != "PROCESSED" || accrual.Accrual != 50050 {
+		t.Errorf("GetOrder = %+v", accrual)
+	}
+	if err := provider.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	span := spans[0]
+	if want := "GET /api/orders/{number}"; span.Name != want {
+		t.Errorf("span name = %q, want %q", span.Name, want)
+	}
+	if span.SpanKind != trace.SpanKindClient {
+		t.Errorf("span kind = %v, want client", span.SpanKind)
+	}
+	if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("span parent = %s, want %s", span.Parent.SpanID(), parent.SpanContext().SpanID())
+	}
+	want := "00-" + span.SpanContext.TraceID().String() + "-" + span.SpanContext.SpanID().String() + "-01"
+	if got := <-traceparent; got != want {
+		t.Errorf("traceparent header = %q, want %q", got, want)
+	}
+}
+
+func TestHTTPAccrualClientStatuses(t *testing.T) {
+	tests := []struct {
+		status int
+		err    error
+	}{
+		{http.StatusNoContent, ErrOrderNotRegistered},
+		{http.StatusInternalServerError, ErrAccrualInternal},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		}))
+		_, err := NewHTTPAccrualClient(server.URL, time.Second).GetOrder(context.Background(), "12345678903")
+		server.Close()
+		if !errors.Is(err, tt.err) {
+			t.Errorf("status %d: error = %v, want %v", tt.status, err, tt.err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte("No more than 10 requests per minute allowed"))
+	}))
+	defer server.Close()
+	_, err := NewHTTPAccrualClient(server.URL, time.Second).GetOrder(context.Background(), "12345678903")
+	var throttled *TooManyRequestsError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("status 429: error = %v, want TooManyRequestsError", err)
+	}
+	if throttled.RetryAfter != 30*time.Second || throttled.Limit != 10 {
+		t.Errorf("status 429: error = %+v, want 30s and 10 per minute", throttled)
+	}
+}
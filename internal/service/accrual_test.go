@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"gofermart/internal/models"
+	"gofermart/internal/storage"
+)
+
+// accrualRepo records the calls AccrualService.process makes; any other
+// repository method panics on the nil embedded interface.
+type accrualRepo struct {
+	storage.Repository
+	setAccrualErr error
+
+	accrualSet    bool
+	status        string
+	accrual       models.Money
+	rescheduled   bool
+	attempts      int
+	next          time.Time
+	notRegistered bool
+}
+
+func (r *accrualRepo) SetAccrual(_ context.Context, _ models.OrderNumber, status string, accrual models.Money) error {
+	if r.setAccrualErr != nil {
+
+		return r.setAccrualErr
+	}
+	r.accrualSet = true
+	r.status = status
+	r.accrual = accrual
+
+	return nil
+}
+
+func (r *accrualRepo) RescheduleOrder(_ context.Context, _ models.OrderNumber, attempts int, next time.Time) error {
+	r.rescheduled = true
+	r.attempts = attempts
+	r.next = next
+
+	return nil
+}
+
+func (r *accrualRepo) MarkOrderNotRegistered(_ context.Context, _ models.OrderNumber, next time.Time) error {
+	r.notRegistered = true
+	r.next = next
+
+	return nil
+}
+
+func TestAccrualServiceProcess(t *testing.T) {
+	const number = models.OrderNumber("12345678903")
+	tests := []struct {
+		name          string
+		accrual       *Accrual
+		err           error
+		setAccrualErr error
+		age           time.Duration
+		attempts      int
+
+		accrualSet    bool
+		status        string
+		amount        models.Money
+		notRegistered bool
+		rescheduled   bool
+		wantAttempts  int
+		wantNext      time.Duration
+	}{
+		{
+			name:        "processed",
+			accrual:     &Accrual{Order: number, Status: "PROCESSED", Accrual: 50050},
+			attempts:    2,
+			accrualSet:  true,
+			status:      "PROCESSED",
+			amount:      50050,
+			rescheduled: true,
+			wantNext:    pollInterval,
+		},
+		{
+			name:          "not registered within max age",
+			err:           ErrOrderNotRegistered,
+			age:           time.Minute,
+			notRegistered: true,
+			wantNext:      backoff(1),
+		},
+		{
+			name:       "not registered past max age",
+			err:        ErrOrderNotRegistered,
+			age:        2 * time.Hour,
+			accrualSet: true,
+			status:     "INVALID",
+		},
+		{
+			name:         "internal error",
+			err:          ErrAccrualInternal,
+			attempts:     3,
+			rescheduled:  true,
+			wantAttempts: 4,
+			wantNext:     backoff(4),
+		},
+		{
+			name:          "set accrual failed",
+			accrual:       &Accrual{Order: number, Status: "PROCESSED", Accrual: 100},
+			setAccrualErr: storage.ErrEntryConflict,
+			rescheduled:   true,
+			wantAttempts:  1,
+			wantNext:      backoff(1),
+		},
+	}
+	for _, tt := range tests {
+		repo := &accrualRepo{setAccrualErr: tt.setAccrualErr}
+		client := NewMemoryAccrualClient()
+		if tt.accrual != nil {
+			client.SetOrder(number, *tt.accrual)
+		}
+		if tt.err != nil {
+			client.SetError(number, tt.err)
+		}
+		s := NewAccrualService(&storage.DB{Repo: repo}, client, 1, 1, time.Hour,
+			slog.New(slog.NewTextHandler(io.Discard, nil)))
+		order := models.Order{
+			OrderNumber:  number,
+			Status:       "NEW",
+			PollAttempts: tt.attempts,
+			CreatedAt:    time.Now().Add(-tt.age),
+		}
+
+		start := time.Now()
+		s.process(context.Background(), order)
+
+		if repo.accrualSet != tt.accrualSet || repo.status != tt.status || repo.accrual != tt.amount {
+			t.Errorf("%s: SetAccrual called %v with %q %d, want %v with %q %d", tt.name,
+				repo.accrualSet, repo.status, repo.accrual, tt.accrualSet, tt.status, tt.amount)
+		}
+		if repo.notRegistered != tt.notRegistered {
+			t.Errorf("%s: MarkOrderNotRegistered called %v, want %v", tt.name, repo.notRegistered, tt.notRegistered)
+		}
+		if repo.rescheduled != tt.rescheduled {
+			t.Errorf("%s: RescheduleOrder called %v, want %v", tt.name, repo.rescheduled, tt.rescheduled)
+
+			continue
+		}
+		if repo.attempts != tt.wantAttempts {
+			t.Errorf("%s: attempts = %d, want %d", tt.name, repo.attempts, tt.wantAttempts)
+		}
+		if tt.wantNext == 0 {
+			continue
+		}
+		if next := repo.next.Sub(start); next < tt.wantNext || next > tt.wantNext+time.Second {
+			t.Errorf("%s: next poll in %v, want %v", tt.name, next, tt.wantNext)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, backoffBase},
+		{1, backoffBase},
+		{2, 2 * backoffBase},
+		{4, 8 * backoffBase},
+		{9, 256 * backoffBase},
+		{10, backoffMaximum},
+		{100, backoffMaximum},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestAccrualResult(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&TooManyRequestsError{RetryAfter: time.Second}, "throttled"},
+		{ErrOrderNotRegistered, "not_registered"},
+		{ErrAccrualInternal, "internal_error"},
+		{errors.New("dial tcp: connection refused"), "error"},
+	}
+	for _, tt := range tests {
+		if got := accrualResult(tt.err); got != tt.want {
+			t.Errorf("accrualResult(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
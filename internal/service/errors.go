@@ -0,0 +1,22 @@
+package service
+
+import (
+	"errors"
+
+	"gofermart/internal/models"
+)
+
+// Domain errors shared by handlers. The handler package maps each of them to
+// an HTTP status and an error code.
+var (
+	ErrMalformedRequest   = errors.New("malformed request")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrWrongCredentials   = errors.New("wrong login or password")
+	ErrWrongPassword      = errors.New("wrong password")
+	ErrLoginTaken         = errors.New("login already exists")
+	ErrTooManyAttempts    = errors.New("too many login attempts")
+	ErrInvalidOrderNumber = models.ErrInvalidOrderNumber
+	ErrOrderTaken         = errors.New("order already uploaded by another user")
+	ErrInvalidAmount      = errors.New("invalid amount")
+	ErrNotFound           = errors.New("not found")
+)
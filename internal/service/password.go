@@ -0,0 +1,40 @@
+package service
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordMaxBytes is the longest password bcrypt hashes; it rejects longer
+// ones with bcrypt.ErrPasswordTooLong.
+const PasswordMaxBytes = 72
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// CheckPassword verifies the password against the stored value. Users
+// registered before bcrypt have the AES-encrypted login stored instead of a
+// hash; for them rehash is true so the caller can upgrade the record.
+func CheckPassword(stored string, login string, password string) (ok bool, rehash bool) {
+	if !strings.HasPrefix(stored, "$2") {
+		legacy := SetCookieValue(login, password)
+
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(legacy)) == 1, true
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)); err != nil {
+
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(stored))
+
+	return true, err != nil || cost < bcrypt.DefaultCost
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultRetryAfter = 60 * time.Second
+
+var limitPattern = regexp.MustCompile(`No more than (\d+) requests per minute allowed`)
+
+// accrualThrottle keeps outgoing accrual requests within the limits
+// announced by the accrual system in its 429 responses.
+type accrualThrottle struct {
+	mu          sync.Mutex
+	pausedUntil time.Time
+	interval    time.Duration
+	next        time.Time
+}
+
+func newAccrualThrottle() *accrualThrottle {
+
+	return &accrualThrottle{}
+}
+
+// delay reserves a slot for the next request and returns how long the caller
+// has to wait before sending it.
+func (t *accrualThrottle) delay() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	slot := now
+	if t.pausedUntil.After(slot) {
+		slot = t.pausedUntil
+	}
+	if t.interval > 0 {
+		if t.next.After(slot) {
+			slot = t.next
+		}
+		t.next = slot.Add(t.interval)
+	}
+
+	return slot.Sub(now)
+}
+
+// remaining reports how long requests stay paused after a 429 response.
+func (t *accrualThrottle) remaining() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if d := time.Until(t.pausedUntil); d > 0 {
+
+		return d
+	}
+
+	return 0
+}
+
+// backlog estimates how long the last of n more requests waits for its slot.
+// Unlike delay it reserves nothing.
+func (t *accrualThrottle) backlog(n int) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	slot := now
+	if t.pausedUntil.After(slot) {
+		slot = t.pausedUntil
+	}
+	if t.interval > 0 && t.next.After(slot) {
+		slot = t.next
+	}
+
+	return slot.Sub(now) + time.Duration(n)*t.interval
+}
+
+// pause stops all requests for the given window and, when the accrual system
+// told us its per-minute limit, spreads further requests evenly over a minute.
+func (t *accrualThrottle) pause(retryAfter time.Duration, limit int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if until := time.Now().Add(retryAfter); until.After(t.pausedUntil) {
+		t.pausedUntil = until
+	}
+	if limit > 0 {
+		t.interval = time.Minute / time.Duration(limit)
+	}
+	t.next = t.pausedUntil
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+
+		return defaultRetryAfter
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+
+			return d
+		}
+
+		return 0
+	}
+
+	return defaultRetryAfter
+}
+
+func parseRequestLimit(body []byte) int {
+	match := limitPattern.FindSubmatch(body)
+	if match == nil {
+
+		return 0
+	}
+	limit, err := strconv.Atoi(string(match[1]))
+	if err != nil {
+
+		return 0
+	}
+
+	return limit
+}
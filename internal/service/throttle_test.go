@@ -0,0 +1,81 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"", defaultRetryAfter, defaultRetryAfter},
+		{"0", 0, 0},
+		{"120", 120 * time.Second, 120 * time.Second},
+		{"-5", defaultRetryAfter, defaultRetryAfter},
+		{"soon", defaultRetryAfter, defaultRetryAfter},
+		{time.Now().Add(2 * time.Minute).UTC().Format(http.TimeFormat), 118 * time.Second, 120 * time.Second},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %v, want between %v and %v", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestParseRequestLimit(t *testing.T) {
+	tests := []struct {
+		body string
+		want int
+	}{
+		{"No more than 10 requests per minute allowed", 10},
+		{"No more than 600 requests per minute allowed\n", 600},
+		{"No more than requests per minute allowed", 0},
+		{"No more than 99999999999999999999 requests per minute allowed", 0},
+		{"Too Many Requests", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRequestLimit([]byte(tt.body)); got != tt.want {
+			t.Errorf("parseRequestLimit(%q) = %d, want %d", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestAccrualThrottle(t *testing.T) {
+	const slack = 100 * time.Millisecond
+
+	throttle := newAccrualThrottle()
+	if d := throttle.delay(); d != 0 {
+		t.Fatalf("delay before any pause = %v, want 0", d)
+	}
+
+	throttle.pause(2*time.Second, 60)
+	if d := throttle.remaining(); d < 2*time.Second-slack || d > 2*time.Second {
+		t.Errorf("remaining = %v, want 2s", d)
+	}
+	if d := throttle.backlog(3); d < 5*time.Second-slack || d > 5*time.Second {
+		t.Errorf("backlog(3) = %v, want 5s", d)
+	}
+	// the first request waits for the pause to end, the following ones get
+	// a slot a second apart each
+	for i := 0; i < 4; i++ {
+		want := 2*time.Second + time.Duration(i)*time.Second
+		if d := throttle.delay(); d < want-slack || d > want {
+			t.Errorf("delay #%d = %v, want %v", i+1, d, want)
+		}
+	}
+	if d := throttle.backlog(0); d < 6*time.Second-slack || d > 6*time.Second {
+		t.Errorf("backlog(0) after four slots = %v, want 6s", d)
+	}
+
+	// a shorter pause does not cut the current one
+	throttle.pause(time.Second, 0)
+	if d := throttle.remaining(); d < 2*time.Second-slack {
+		t.Errorf("remaining after a shorter pause = %v, want 2s", d)
+	}
+}
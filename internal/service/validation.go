@@ -0,0 +1,126 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gofermart/internal/config"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors lists every rule an input broke, field by field.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, fieldError := range e {
+		messages = append(messages, fmt.Sprintf("%s: %s", fieldError.Field, fieldError.Message))
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+type CredentialsPolicy struct {
+	LoginMinLength    int
+	LoginMaxLength    int
+	LoginPattern      *regexp.Regexp
+	PasswordMinLength int
+	PasswordMaxBytes  int
+	RequireDigit      bool
+	RequireMixedCase  bool
+	RequireSymbol     bool
+}
+
+func NewCredentialsPolicy() CredentialsPolicy {
+
+	return CredentialsPolicy{
+		LoginMinLength:    config.GetConfigLoginMinLength(),
+		LoginMaxLength:    config.GetConfigLoginMaxLength(),
+		LoginPattern:      regexp.MustCompile(config.GetConfigLoginPattern()),
+		PasswordMinLength: config.GetConfigPasswordMinLength(),
+		PasswordMaxBytes:  PasswordMaxBytes,
+		RequireDigit:      config.GetConfigPasswordRequireDigit(),
+		RequireMixedCase:  config.GetConfigPasswordRequireMixedCase(),
+		RequireSymbol:     config.GetConfigPasswordRequireSymbol(),
+	}
+}
+
+// NormalizeLogin makes logins case-insensitive: "Alice " and "alice" are the
+// same user.
+func NormalizeLogin(login string) string {
+
+	return strings.ToLower(strings.TrimSpace(login))
+}
+
+func (p CredentialsPolicy) ValidateRegistration(login string, password string) ValidationErrors {
+	errs := ValidationErrors{}
+	errs = append(errs, p.ValidateLogin(login)...)
+	errs = append(errs, p.ValidatePassword("password", password)...)
+
+	return errs
+}
+
+func (p CredentialsPolicy) ValidateLogin(login string) ValidationErrors {
+	errs := ValidationErrors{}
+	length := utf8.RuneCountInString(login)
+	switch {
+	case length == 0:
+		errs = append(errs, FieldError{"login", "is required"})
+	case length < p.LoginMinLength:
+		errs = append(errs, FieldError{"login", fmt.Sprintf("must be at least %d characters long", p.LoginMinLength)})
+	case p.LoginMaxLength > 0 && length > p.LoginMaxLength:
+		errs = append(errs, FieldError{"login", fmt.Sprintf("must be at most %d characters long", p.LoginMaxLength)})
+	}
+	if length > 0 && p.LoginPattern != nil && !p.LoginPattern.MatchString(login) {
+		errs = append(errs, FieldError{"login", "contains characters that are not allowed"})
+	}
+
+	return errs
+}
+
+func (p CredentialsPolicy) ValidatePassword(field string, password string) ValidationErrors {
+	errs := ValidationErrors{}
+	if password == "" {
+
+		return append(errs, FieldError{field, "is required"})
+	}
+	if utf8.RuneCountInString(password) < p.PasswordMinLength {
+		errs = append(errs, FieldError{field, fmt.Sprintf("must be at least %d characters long", p.PasswordMinLength)})
+	}
+	// bytes, not characters: the limit comes from bcrypt
+	if p.PasswordMaxBytes > 0 && len(password) > p.PasswordMaxBytes {
+		errs = append(errs, FieldError{field, fmt.Sprintf("must be at most %d bytes long", p.PasswordMaxBytes)})
+	}
+
+	var digit, upper, lower, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+	if p.RequireDigit && !digit {
+		errs = append(errs, FieldError{field, "must contain a digit"})
+	}
+	if p.RequireMixedCase && !(upper && lower) {
+		errs = append(errs, FieldError{field, "must contain both upper and lower case letters"})
+	}
+	if p.RequireSymbol && !symbol {
+		errs = append(errs, FieldError{field, "must contain a symbol"})
+	}
+
+	return errs
+}
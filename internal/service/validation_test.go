@@ -0,0 +1,86 @@
+package service
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCredentialsPolicyValidateRegistration(t *testing.T) {
+	policy := CredentialsPolicy{
+		LoginMinLength:    3,
+		LoginMaxLength:    10,
+		LoginPattern:      regexp.MustCompile(`^[a-zA-Z0-9._-]+$`),
+		PasswordMinLength: 8,
+		PasswordMaxBytes:  PasswordMaxBytes,
+		RequireDigit:      true,
+		RequireMixedCase:  true,
+		RequireSymbol:     true,
+	}
+	tests := []struct {
+		name     string
+		login    string
+		password string
+		want     ValidationErrors
+	}{
+		{"valid", "alice", "Secret-42", ValidationErrors{}},
+		{"empty", "", "", ValidationErrors{
+			{"login", "is required"},
+			{"password", "is required"},
+		}},
+		{"login too short", "al", "Secret-42", ValidationErrors{
+			{"login", "must be at least 3 characters long"},
+		}},
+		{"login too long", "alice.liddell", "Secret-42", ValidationErrors{
+			{"login", "must be at most 10 characters long"},
+		}},
+		{"login pattern", "alice!", "Secret-42", ValidationErrors{
+			{"login", "contains characters that are not allowed"},
+		}},
+		{"login length and pattern", "алиса.лидделл", "Secret-42", ValidationErrors{
+			{"login", "must be at most 10 characters long"},
+			{"login", "contains characters that are not allowed"},
+		}},
+		{"password too short", "alice", "Se-42", ValidationErrors{
+			{"password", "must be at least 8 characters long"},
+		}},
+		{"multibyte password at the byte limit", "alice", "Aa1!" + strings.Repeat("ж", 34), ValidationErrors{}},
+		{"multibyte password past the byte limit", "alice", "Aa1!" + strings.Repeat("ж", 35), ValidationErrors{
+			{"password", "must be at most 72 bytes long"},
+		}},
+		{"no digit", "alice", "Secret-pass", ValidationErrors{
+			{"password", "must contain a digit"},
+		}},
+		{"no upper case", "alice", "secret-42", ValidationErrors{
+			{"password", "must contain both upper and lower case letters"},
+		}},
+		{"no lower case", "alice", "SECRET-42", ValidationErrors{
+			{"password", "must contain both upper and lower case letters"},
+		}},
+		{"no symbol", "alice", "Secret42", ValidationErrors{
+			{"password", "must contain a symbol"},
+		}},
+		{"every password rule", "alice", "secret", ValidationErrors{
+			{"password", "must be at least 8 characters long"},
+			{"password", "must contain a digit"},
+			{"password", "must contain both upper and lower case letters"},
+			{"password", "must contain a symbol"},
+		}},
+	}
+	for _, tt := range tests {
+		if got := policy.ValidateRegistration(tt.login, tt.password); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ValidateRegistration(%q, %q) = %v, want %v", tt.name, tt.login, tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestCredentialsPolicyOptionalRules(t *testing.T) {
+	policy := CredentialsPolicy{LoginMinLength: 1, PasswordMinLength: 1}
+	if errs := policy.ValidateRegistration("Алиса Лидделл", "secret"); len(errs) != 0 {
+		t.Errorf("policy without pattern and password rules rejected: %v", errs)
+	}
+	if errs := policy.ValidatePassword("new_password", ""); !reflect.DeepEqual(errs, ValidationErrors{{"new_password", "is required"}}) {
+		t.Errorf("ValidatePassword(%q) = %v, want the field named new_password", "", errs)
+	}
+}
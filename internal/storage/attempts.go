@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"gofermart/internal/models"
+)
+
+// UpdateLoginAttempts locks the counters of keys, creating missing ones, and
+// runs fn on them in one transaction, so concurrent logins see each other's
+// changes. Counters fn leaves without failures or a lock are deleted.
+func (r *repository) UpdateLoginAttempts(ctx context.Context, keys []string, fn func(attempts map[string]*models.LoginAttempt) error) error {
+	ctx, span := startSpan(ctx, "UpdateLoginAttempts")
+	defer span.End()
+
+	// a fixed lock order keeps two logins sharing a key from deadlocking
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		rows := make([]models.LoginAttempt, 0, len(sorted))
+		for _, key := range sorted {
+			rows = append(rows, models.LoginAttempt{Key: key})
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+
+			return err
+		}
+		locked := []models.LoginAttempt{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("key IN ?", sorted).Order("key").Find(&locked).Error; err != nil {
+
+			return err
+		}
+		attempts := make(map[string]*models.LoginAttempt, len(locked))
+		for i := range locked {
+			attempts[locked[i].Key] = &locked[i]
+		}
+		if err := fn(attempts); err != nil {
+
+			return err
+		}
+
+		now := time.Now()
+		for key, attempt := range attempts {
+			if attempt.Failures == 0 && !attempt.LockedUntil.After(now) {
+				if err := tx.Delete(&models.LoginAttempt{}, "key = ?", key).Error; err != nil {
+
+					return err
+				}
+				continue
+			}
+			err := tx.Model(&models.LoginAttempt{}).Where("key = ?", key).UpdateColumns(map[string]interface{}{
+				"failures":        attempt.Failures,
+				"last_failure_at": attempt.LastFailureAt,
+				"locked_until":    attempt.LockedUntil,
+			}).Error
+			if err != nil {
+
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *repository) CreateAuditEvent(ctx context.Context, m *models.AuditEvent) error {
+	ctx, span := startSpan(ctx, "CreateAuditEvent")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Create(m).Error
+}
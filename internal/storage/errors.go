@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// Errors for rows rejected by the schema constraints.
+var (
+	ErrLoginExists      = errors.New("login already exists")
+	ErrOrderExists      = errors.New("order already exists")
+	ErrWithdrawalExists = errors.New("withdrawal for this order already exists")
+	ErrUserNotFound     = errors.New("user not found")
+	ErrNegativeAmount   = errors.New("amount out of range")
+	ErrInvalidStatus    = errors.New("invalid order status")
+	ErrConstraint       = errors.New("constraint violation")
+)
+
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgCheckViolation      = "23514"
+)
+
+// constraintErrors maps the constraint names of 0001_initial_schema and
+// 0003_constraints to the errors returned for them.
+var constraintErrors = map[string]error{
+	"users_login_key":          ErrLoginExists,
+	"users_login_lower":        ErrLoginExists,
+	"orders_order_number_key":  ErrOrderExists,
+	"balances_order_id_key":    ErrWithdrawalExists,
+	"orders_user_fk":           ErrUserNotFound,
+	"balances_user_fk":         ErrUserNotFound,
+	"accounts_user_fk":         ErrUserNotFound,
+	"sessions_user_fk":         ErrUserNotFound,
+	"refresh_tokens_user_fk":   ErrUserNotFound,
+	"password_resets_user_fk":  ErrUserNotFound,
+	"journal_entries_user_fk":  ErrUserNotFound,
+	"orders_status_check":      ErrInvalidStatus,
+	"orders_accrual_check":     ErrNegativeAmount,
+	"balances_withdraw_check":  ErrNegativeAmount,
+	"accounts_withdrawn_check": ErrNegativeAmount,
+	"postings_amount_check":    ErrNegativeAmount,
+}
+
+// ConstraintError is returned when PostgreSQL rejects a write. It matches
+// one of the errors above with errors.Is, ErrConstraint for constraints
+// without an error of their own.
+type ConstraintError struct {
+	Constraint string
+	Err        error
+	cause      *pgconn.PgError
+}
+
+func (e *ConstraintError) Error() string {
+
+	return e.Err.Error()
+}
+
+func (e *ConstraintError) Is(target error) bool {
+
+	return target == e.Err
+}
+
+func (e *ConstraintError) Unwrap() error {
+
+	return e.cause
+}
+
+// translateError turns constraint violations into ConstraintError and
+// returns any other error unchanged.
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+
+		return err
+	}
+	switch pgErr.Code {
+	case pgUniqueViolation, pgForeignKeyViolation, pgCheckViolation:
+	default:
+
+		return err
+	}
+
+	known, ok := constraintErrors[pgErr.ConstraintName]
+	if !ok {
+		known = ErrConstraint
+	}
+
+	return &ConstraintError{
+		Constraint: pgErr.ConstraintName,
+		Err:        known,
+		cause:      pgErr,
+	}
+}
@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"gofermart/internal/models"
+)
+
+var (
+	ErrUnbalancedEntry = errors.New("journal entry is not balanced")
+	ErrEntryConflict   = errors.New("journal entry already posted for another change")
+)
+
+func (r *repository) PostEntry(ctx context.Context, entry *models.JournalEntry) error {
+	ctx, span := startSpan(ctx, "PostEntry")
+	defer span.End()
+
+	return translateError(r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		_, err := postEntry(tx, entry)
+
+		return err
+	}))
+}
+
+// Adjust posts a manual correction of the user's points. A negative amount
+// may not take the balance below zero. The caller's key is prefixed with
+// "adjustment:", so it cannot collide with the keys of system entries.
+func (r *repository) Adjust(ctx context.Context, userID uint64, amount models.Money, key string, description string) error {
+	ctx, span := startSpan(ctx, "Adjust")
+	defer span.End()
+
+	return translateError(r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		account, err := lockAccount(tx, userID)
+		if err != nil {
+
+			return err
+		}
+		if account.Current+amount < 0 {
+
+			return ErrInsufficientFunds
+		}
+		user := models.UserLedgerAccount(userID)
+		entry := &models.JournalEntry{
+			IdempotencyKey: models.EntryAdjustment + ":" + key,
+			Kind:           models.EntryAdjustment,
+			UserID:         userID,
+			Description:    description,
+		}
+		if amount >= 0 {
+			entry.Postings = transfer(models.LedgerAdjustmentAccount, user, amount)
+		} else {
+			entry.Postings = transfer(user, models.LedgerAdjustmentAccount, -amount)
+		}
+		_, err = postEntry(tx, entry)
+
+		return err
+	}))
+}
+
+func (r *repository) LedgerBalance(ctx context.Context, userID uint64) (models.Money, error) {
+	ctx, span := startSpan(ctx, "LedgerBalance")
+	defer span.End()
+
+	balance := models.Money(0)
+	err := r.db.WithContext(ctx).Model(&models.Posting{}).Where("account = ?", models.UserLedgerAccount(userID)).
+		Select("COALESCE(SUM(credit - debit), 0)").Scan(&balance).Error
+
+	return balance, err
+}
+
+func (r *repository) LedgerStatement(ctx context.Context, userID uint64) ([]models.StatementLine, error) {
+	ctx, span := startSpan(ctx, "LedgerStatement")
+	defer span.End()
+
+	lines := []models.StatementLine{}
+	err := r.db.WithContext(ctx).Table("postings p").
+		Select("e.id AS entry_id, e.kind, e.order_number, e.description, p.credit - p.debit AS amount, e.created_at").
+		Joins("JOIN journal_entries e ON e.id = p.entry_id").
+		Where("p.account = ?", models.UserLedgerAccount(userID)).
+		Order("e.id").
+		Scan(&lines).Error
+	if err != nil {
+
+		return nil, err
+	}
+	balance := models.Money(0)
+	for i := range lines {
+		balance += lines[i].Amount
+		lines[i].Balance = balance
+	}
+
+	return lines, nil
+}
+
+// postEntry stores the entry and applies it to the materialized user account.
+// It reports false when an entry with the same idempotency key already exists.
+func postEntry(tx *gorm.DB, entry *models.JournalEntry) (bool, error) {
+	posted, err := insertEntry(tx, entry)
+	if err != nil || !posted {
+
+		return posted, err
+	}
+
+	user := models.UserLedgerAccount(entry.UserID)
+	delta, withdrawn := models.Money(0), models.Money(0)
+	for _, posting := range entry.Postings {
+		if posting.Account != user {
+			continue
+		}
+		delta += posting.Credit - posting.Debit
+		if entry.Kind == models.EntryWithdrawal {
+			withdrawn += posting.Debit
+		}
+	}
+	if delta == 0 && withdrawn == 0 {
+
+		return true, nil
+	}
+	account, err := lockAccount(tx, entry.UserID)
+	if err != nil {
+
+		return false, err
+	}
+	err = tx.Model(account).UpdateColumns(map[string]interface{}{
+		"current":    gorm.Expr("current + ?", delta),
+		"withdrawn":  gorm.Expr("withdrawn + ?", withdrawn),
+		"updated_at": gorm.Expr("NOW()"),
+	}).Error
+
+	return err == nil, err
+}
+
+func insertEntry(tx *gorm.DB, entry *models.JournalEntry) (bool, error) {
+	if err := validateEntry(entry); err != nil {
+
+		return false, err
+	}
+	postings := entry.Postings
+	entry.Postings = nil
+	defer func() { entry.Postings = postings }()
+
+	result := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "idempotency_key"}},
+		DoNothing: true,
+	}).Create(entry)
+	if result.Error != nil {
+
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+
+		return false, nil
+	}
+	for i := range postings {
+		postings[i].EntryID = entry.ID
+	}
+
+	return true, tx.Create(&postings).Error
+}
+
+func validateEntry(entry *models.JournalEntry) error {
+	if entry.IdempotencyKey == "" {
+
+		return fmt.Errorf("%w: empty idempotency key", ErrUnbalancedEntry)
+	}
+	if len(entry.Postings) < 2 {
+
+		return fmt.Errorf("%w: at least two postings required", ErrUnbalancedEntry)
+	}
+	debit, credit := models.Money(0), models.Money(0)
+	for _, posting := range entry.Postings {
+		if posting.Debit < 0 || posting.Credit < 0 || (posting.Debit == 0) == (posting.Credit == 0) {
+
+			return fmt.Errorf("%w: posting must have exactly one positive leg", ErrUnbalancedEntry)
+		}
+		if strings.HasPrefix(posting.Account, "user:") && posting.Account != models.UserLedgerAccount(entry.UserID) {
+
+			return fmt.Errorf("%w: posting to another user's account", ErrUnbalancedEntry)
+		}
+		debit += posting.Debit
+		credit += posting.Credit
+	}
+	if debit != credit {
+
+		return ErrUnbalancedEntry
+	}
+
+	return nil
+}
+
+// transfer moves amount from the debited account to the credited one.
+func transfer(debit string, credit string, amount models.Money) []models.Posting {
+
+	return []models.Posting{
+		{Account: debit, Debit: amount},
+		{Account: credit, Credit: amount},
+	}
+}
+
+// accrualEntry posts the change of the order accrual from previous to its
+// current value. The key holds the time of the order's last update, read
+// under its row lock, so it is unique per change even when the same amounts
+// come back.
+func accrualEntry(order *models.Order, previous models.Money, changedAt time.Time) *models.JournalEntry {
+	delta := order.Accrual - previous
+	user := models.UserLedgerAccount(order.UserID)
+	entry := &models.JournalEntry{
+		UserID:      order.UserID,
+		OrderNumber: order.OrderNumber,
+	}
+	if delta >= 0 {
+		entry.Kind = models.EntryAccrual
+		entry.Postings = transfer(models.LedgerAccrualAccount, user, delta)
+	} else {
+		entry.Kind = models.EntryReversal
+		entry.Postings = transfer(user, models.LedgerAccrualAccount, -delta)
+	}
+	entry.IdempotencyKey = fmt.Sprintf("%s:%s:%d:%s:%s",
+		entry.Kind, order.OrderNumber, changedAt.UnixMicro(), previous, order.Accrual)
+
+	return entry
+}
+
+func withdrawalEntry(m *models.Balance) *models.JournalEntry {
+
+	return &models.JournalEntry{
+		IdempotencyKey: fmt.Sprintf("%s:%s", models.EntryWithdrawal, m.OrderID),
+		Kind:           models.EntryWithdrawal,
+		UserID:         m.UserID,
+		OrderNumber:    m.OrderID,
+		Postings:       transfer(models.UserLedgerAccount(m.UserID), models.LedgerWithdrawalAccount, m.Withdraw),
+	}
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	gormlogger "gorm.io/gorm/logger"
+
+	"gofermart/internal/logger"
+)
+
+const slowQuery = 200 * time.Millisecond
+
+// gormLogger sends gorm messages to the request logger found in the query
+// context, the application logger otherwise. Queries are logged at debug
+// level, slow and failed ones as warnings and errors.
+type gormLogger struct {
+	logger *slog.Logger
+}
+
+func newGormLogger(logger *slog.Logger) gormlogger.Interface {
+
+	return &gormLogger{logger: logger.With("component", "gorm")}
+}
+
+// LogMode is a no-op: the level is set on the application logger.
+func (l *gormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+
+	return l
+}
+
+// from returns the logger of ctx, so query logs carry its request and user IDs.
+func (l *gormLogger) from(ctx context.Context) *slog.Logger {
+	if log := logger.FromContext(ctx, nil); log != nil {
+
+		return log.With("component", "gorm")
+	}
+
+	return l.logger
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	l.from(ctx).InfoContext(ctx, msg, "args", args)
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	l.from(ctx).WarnContext(ctx, msg, "args", args)
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	l.from(ctx).ErrorContext(ctx, msg, "args", args)
+}
+
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	log := l.from(ctx)
+	switch {
+	case err != nil && !errors.Is(err, gormlogger.ErrRecordNotFound):
+		sql, rows := fc()
+		log.ErrorContext(ctx, "query failed", "sql", sql, "rows", rows, "elapsed", elapsed, "error", err)
+	case elapsed > slowQuery:
+		sql, rows := fc()
+		log.WarnContext(ctx, "slow query", "sql", sql, "rows", rows, "elapsed", elapsed)
+	case log.Enabled(ctx, slog.LevelDebug):
+		sql, rows := fc()
+		log.DebugContext(ctx, "query", "sql", sql, "rows", rows, "elapsed", elapsed)
+	}
+}
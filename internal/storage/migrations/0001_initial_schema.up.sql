@@ -0,0 +1,162 @@
+-- Baseline schema. Every statement is idempotent, so databases created by
+-- earlier versions through gorm's CreateTable are brought to the same shape.
+
+CREATE TABLE IF NOT EXISTS users (
+    id         bigserial PRIMARY KEY,
+    login      text NOT NULL UNIQUE,
+    password   text NOT NULL,
+    created_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+    id           bigserial PRIMARY KEY,
+    user_id      bigint,
+    order_number bigint UNIQUE,
+    status       text NOT NULL,
+    accrual      bigint NOT NULL DEFAULT 0,
+    created_at   timestamptz,
+    updated_at   timestamptz
+);
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS poll_attempts bigint NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS next_poll_at timestamptz;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS not_registered_attempts bigint NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS user_id ON orders (user_id);
+CREATE INDEX IF NOT EXISTS next_poll_at ON orders (next_poll_at);
+
+CREATE TABLE IF NOT EXISTS balances (
+    id         bigserial PRIMARY KEY,
+    user_id    bigint,
+    order_id   bigint UNIQUE,
+    withdraw   bigint NOT NULL DEFAULT 0,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS balances_user_id ON balances (user_id);
+
+CREATE TABLE IF NOT EXISTS accounts (
+    user_id    bigint PRIMARY KEY,
+    current    bigint NOT NULL DEFAULT 0,
+    withdrawn  bigint NOT NULL DEFAULT 0,
+    updated_at timestamptz
+);
+
+-- amounts were stored as float points before they became integer hundredths
+DO $$
+DECLARE
+    c record;
+BEGIN
+    FOR c IN
+        SELECT table_name, column_name FROM information_schema.columns
+        WHERE table_schema = current_schema()
+            AND (table_name, column_name) IN (('orders', 'accrual'), ('balances', 'withdraw'),
+                ('accounts', 'current'), ('accounts', 'withdrawn'))
+            AND data_type <> 'bigint'
+    LOOP
+        EXECUTE format('ALTER TABLE %1$I ALTER COLUMN %2$I DROP DEFAULT, '
+            'ALTER COLUMN %2$I TYPE bigint USING ROUND(%2$I * 100)::bigint, '
+            'ALTER COLUMN %2$I SET DEFAULT 0', c.table_name, c.column_name);
+    END LOOP;
+END
+$$;
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id           varchar(64) PRIMARY KEY,
+    user_id      bigint NOT NULL,
+    user_agent   text,
+    ip           text,
+    created_at   timestamptz,
+    last_seen_at timestamptz,
+    expires_at   timestamptz NOT NULL,
+    revoked_at   timestamptz
+);
+CREATE INDEX IF NOT EXISTS session_user_id ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS session_expires_at ON sessions (expires_at);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id         bigserial PRIMARY KEY,
+    user_id    bigint NOT NULL,
+    session_id varchar(64) NOT NULL,
+    family_id  varchar(64) NOT NULL,
+    token_hash varchar(64) NOT NULL UNIQUE,
+    created_at timestamptz,
+    expires_at timestamptz NOT NULL,
+    used_at    timestamptz,
+    revoked_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS refresh_user_id ON refresh_tokens (user_id);
+CREATE INDEX IF NOT EXISTS refresh_session_id ON refresh_tokens (session_id);
+CREATE INDEX IF NOT EXISTS refresh_family_id ON refresh_tokens (family_id);
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key             varchar(255) PRIMARY KEY,
+    failures        bigint NOT NULL DEFAULT 0,
+    last_failure_at timestamptz,
+    locked_until    timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id         bigserial PRIMARY KEY,
+    kind       text NOT NULL,
+    user_id    bigint,
+    subject    text,
+    ip         text,
+    details    text,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS audit_kind ON audit_events (kind);
+CREATE INDEX IF NOT EXISTS audit_user_id ON audit_events (user_id);
+
+CREATE TABLE IF NOT EXISTS password_resets (
+    id         bigserial PRIMARY KEY,
+    user_id    bigint NOT NULL,
+    token_hash varchar(64) NOT NULL UNIQUE,
+    created_at timestamptz,
+    expires_at timestamptz NOT NULL,
+    used_at    timestamptz
+);
+CREATE INDEX IF NOT EXISTS reset_user_id ON password_resets (user_id);
+
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id         bigserial PRIMARY KEY,
+    user_id    bigint,
+    recipient  text NOT NULL,
+    subject    text NOT NULL,
+    body       text NOT NULL,
+    created_at timestamptz,
+    sent_at    timestamptz
+);
+CREATE INDEX IF NOT EXISTS outbox_user_id ON outbox_messages (user_id);
+CREATE INDEX IF NOT EXISTS outbox_sent_at ON outbox_messages (sent_at);
+
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id              bigserial PRIMARY KEY,
+    idempotency_key text NOT NULL UNIQUE,
+    kind            text NOT NULL,
+    user_id         bigint,
+    order_number    bigint,
+    description     text,
+    created_at      timestamptz
+);
+CREATE INDEX IF NOT EXISTS journal_user_id ON journal_entries (user_id);
+CREATE INDEX IF NOT EXISTS journal_order_number ON journal_entries (order_number);
+
+CREATE TABLE IF NOT EXISTS postings (
+    id         bigserial PRIMARY KEY,
+    entry_id   bigint NOT NULL,
+    account    text NOT NULL,
+    debit      bigint NOT NULL DEFAULT 0,
+    credit     bigint NOT NULL DEFAULT 0,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS entry_id ON postings (entry_id);
+CREATE INDEX IF NOT EXISTS account ON postings (account);
+
+-- logins are unique regardless of case; older databases may hold logins
+-- differing only in case, then the index is left out
+DO $$
+BEGIN
+    CREATE UNIQUE INDEX IF NOT EXISTS users_login_lower ON users (lower(login));
+EXCEPTION WHEN unique_violation THEN
+    RAISE WARNING 'users_login_lower not created: logins differing only in case exist';
+END
+$$;
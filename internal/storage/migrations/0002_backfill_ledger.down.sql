@@ -0,0 +1,2 @@
+-- Backfilled accounts and journal entries are indistinguishable from ones
+-- written later, so rolling this migration back keeps them.
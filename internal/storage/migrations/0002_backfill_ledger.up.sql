@@ -0,0 +1,54 @@
+-- Databases from before accounts and the ledger existed hold orders and
+-- withdrawals only. Give every user an account summing them, then record the
+-- matching journal entries. Both steps skip what already exists.
+
+INSERT INTO accounts (user_id, current, withdrawn, updated_at)
+SELECT u.id,
+    COALESCE((SELECT SUM(o.accrual) FROM orders o WHERE o.user_id = u.id), 0) -
+    COALESCE((SELECT SUM(b.withdraw) FROM balances b WHERE b.user_id = u.id), 0),
+    COALESCE((SELECT SUM(b.withdraw) FROM balances b WHERE b.user_id = u.id), 0),
+    NOW()
+FROM users u
+WHERE NOT EXISTS (SELECT 1 FROM accounts a WHERE a.user_id = u.id)
+ON CONFLICT (user_id) DO NOTHING;
+
+WITH entries AS (
+    INSERT INTO journal_entries (idempotency_key, kind, user_id, order_number, description, created_at)
+    SELECT
+        CASE WHEN o.accrual > 0 THEN 'accrual' ELSE 'reversal' END || ':' || o.order_number || ':' || o.status,
+        CASE WHEN o.accrual > 0 THEN 'accrual' ELSE 'reversal' END,
+        o.user_id, o.order_number, '', NOW()
+    FROM orders o
+    WHERE o.accrual <> 0 AND NOT EXISTS (
+        SELECT 1 FROM journal_entries e
+        WHERE e.order_number = o.order_number AND e.kind IN ('accrual', 'reversal'))
+    ON CONFLICT (idempotency_key) DO NOTHING
+    RETURNING id, kind, user_id, order_number
+)
+INSERT INTO postings (entry_id, account, debit, credit, created_at)
+SELECT e.id,
+    CASE WHEN e.kind = 'accrual' THEN 'system:accrual' ELSE 'user:' || e.user_id END,
+    ABS(o.accrual), 0, NOW()
+FROM entries e JOIN orders o ON o.order_number = e.order_number
+UNION ALL
+SELECT e.id,
+    CASE WHEN e.kind = 'accrual' THEN 'user:' || e.user_id ELSE 'system:accrual' END,
+    0, ABS(o.accrual), NOW()
+FROM entries e JOIN orders o ON o.order_number = e.order_number;
+
+WITH entries AS (
+    INSERT INTO journal_entries (idempotency_key, kind, user_id, order_number, description, created_at)
+    SELECT 'withdrawal:' || b.order_id, 'withdrawal', b.user_id, b.order_id, '', NOW()
+    FROM balances b
+    WHERE b.withdraw > 0 AND NOT EXISTS (
+        SELECT 1 FROM journal_entries e
+        WHERE e.order_number = b.order_id AND e.kind = 'withdrawal')
+    ON CONFLICT (idempotency_key) DO NOTHING
+    RETURNING id, user_id, order_number
+)
+INSERT INTO postings (entry_id, account, debit, credit, created_at)
+SELECT e.id, 'user:' || e.user_id, b.withdraw, 0, NOW()
+FROM entries e JOIN balances b ON b.order_id = e.order_number
+UNION ALL
+SELECT e.id, 'system:withdrawal', 0, b.withdraw, NOW()
+FROM entries e JOIN balances b ON b.order_id = e.order_number;
@@ -0,0 +1,38 @@
+ALTER TABLE postings
+    DROP CONSTRAINT IF EXISTS postings_amount_check,
+    DROP CONSTRAINT IF EXISTS postings_entry_fk;
+
+ALTER TABLE journal_entries
+    DROP CONSTRAINT IF EXISTS journal_entries_kind_check,
+    DROP CONSTRAINT IF EXISTS journal_entries_user_fk;
+
+ALTER TABLE login_attempts
+    DROP CONSTRAINT IF EXISTS login_attempts_failures_check;
+
+ALTER TABLE password_resets
+    DROP CONSTRAINT IF EXISTS password_resets_user_fk;
+
+ALTER TABLE refresh_tokens
+    DROP CONSTRAINT IF EXISTS refresh_tokens_session_fk,
+    DROP CONSTRAINT IF EXISTS refresh_tokens_user_fk;
+
+ALTER TABLE sessions
+    DROP CONSTRAINT IF EXISTS sessions_user_fk;
+
+ALTER TABLE accounts
+    DROP CONSTRAINT IF EXISTS accounts_withdrawn_check,
+    DROP CONSTRAINT IF EXISTS accounts_user_fk;
+
+ALTER TABLE balances
+    DROP CONSTRAINT IF EXISTS balances_withdraw_check,
+    DROP CONSTRAINT IF EXISTS balances_user_fk,
+    ALTER COLUMN order_id DROP NOT NULL,
+    ALTER COLUMN user_id DROP NOT NULL;
+
+ALTER TABLE orders
+    DROP CONSTRAINT IF EXISTS orders_poll_attempts_check,
+    DROP CONSTRAINT IF EXISTS orders_accrual_check,
+    DROP CONSTRAINT IF EXISTS orders_status_check,
+    DROP CONSTRAINT IF EXISTS orders_user_fk,
+    ALTER COLUMN order_number DROP NOT NULL,
+    ALTER COLUMN user_id DROP NOT NULL;
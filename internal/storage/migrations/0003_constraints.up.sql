@@ -0,0 +1,45 @@
+-- Referential integrity and value checks. Rows written by older versions
+-- that break them make this migration fail instead of being dropped.
+
+ALTER TABLE orders
+    ALTER COLUMN user_id SET NOT NULL,
+    ALTER COLUMN order_number SET NOT NULL,
+    ADD CONSTRAINT orders_user_fk FOREIGN KEY (user_id) REFERENCES users (id),
+    ADD CONSTRAINT orders_status_check
+        CHECK (status IN ('NEW', 'REGISTERED', 'PROCESSING', 'INVALID', 'PROCESSED')),
+    ADD CONSTRAINT orders_accrual_check CHECK (accrual >= 0),
+    ADD CONSTRAINT orders_poll_attempts_check CHECK (poll_attempts >= 0 AND not_registered_attempts >= 0);
+
+ALTER TABLE balances
+    ALTER COLUMN user_id SET NOT NULL,
+    ALTER COLUMN order_id SET NOT NULL,
+    ADD CONSTRAINT balances_user_fk FOREIGN KEY (user_id) REFERENCES users (id),
+    ADD CONSTRAINT balances_withdraw_check CHECK (withdraw > 0);
+
+-- current is left unchecked: a reversed accrual may take it below zero
+ALTER TABLE accounts
+    ADD CONSTRAINT accounts_user_fk FOREIGN KEY (user_id) REFERENCES users (id),
+    ADD CONSTRAINT accounts_withdrawn_check CHECK (withdrawn >= 0);
+
+ALTER TABLE sessions
+    ADD CONSTRAINT sessions_user_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE refresh_tokens
+    ADD CONSTRAINT refresh_tokens_user_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    ADD CONSTRAINT refresh_tokens_session_fk FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE CASCADE;
+
+ALTER TABLE password_resets
+    ADD CONSTRAINT password_resets_user_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE login_attempts
+    ADD CONSTRAINT login_attempts_failures_check CHECK (failures >= 0);
+
+ALTER TABLE journal_entries
+    ADD CONSTRAINT journal_entries_user_fk FOREIGN KEY (user_id) REFERENCES users (id),
+    ADD CONSTRAINT journal_entries_kind_check
+        CHECK (kind IN ('accrual', 'withdrawal', 'reversal', 'adjustment'));
+
+ALTER TABLE postings
+    ADD CONSTRAINT postings_entry_fk FOREIGN KEY (entry_id) REFERENCES journal_entries (id) ON DELETE CASCADE,
+    ADD CONSTRAINT postings_amount_check
+        CHECK (debit >= 0 AND credit >= 0 AND (debit = 0) <> (credit = 0));
@@ -0,0 +1,8 @@
+-- Fails when a stored number has more digits than bigint holds.
+
+ALTER TABLE journal_entries
+    ALTER COLUMN order_number TYPE bigint USING COALESCE(order_number, '0')::bigint;
+
+ALTER TABLE balances ALTER COLUMN order_id TYPE bigint USING order_id::bigint;
+
+ALTER TABLE orders ALTER COLUMN order_number TYPE bigint USING order_number::bigint;
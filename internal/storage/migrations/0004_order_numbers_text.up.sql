@@ -0,0 +1,10 @@
+-- Order numbers become text so leading zeros and numbers longer than bigint
+-- are kept. Leading zeros already lost in bigint columns cannot be restored.
+
+ALTER TABLE orders ALTER COLUMN order_number TYPE text USING order_number::text;
+
+ALTER TABLE balances ALTER COLUMN order_id TYPE text USING order_id::text;
+
+-- entries without an order used to store 0
+ALTER TABLE journal_entries
+    ALTER COLUMN order_number TYPE text USING NULLIF(order_number, 0)::text;
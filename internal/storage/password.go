@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"gofermart/internal/models"
+)
+
+var ErrResetTokenInvalid = errors.New("password reset token is invalid or expired")
+
+func (r *repository) CreatePasswordReset(ctx context.Context, m *models.PasswordReset) error {
+	ctx, span := startSpan(ctx, "CreatePasswordReset")
+	defer span.End()
+
+	return translateError(r.db.WithContext(ctx).Create(m).Error)
+}
+
+// UsePasswordReset marks the reset token as used, so it works exactly once,
+// and stores the new password hash of its user in the same transaction.
+func (r *repository) UsePasswordReset(ctx context.Context, hash string, password string) (*models.PasswordReset, error) {
+	ctx, span := startSpan(ctx, "UsePasswordReset")
+	defer span.End()
+
+	model := &models.PasswordReset{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hash).First(model).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+
+				return ErrResetTokenInvalid
+			}
+
+			return err
+		}
+		now := time.Now()
+		if model.UsedAt != nil || !now.Before(model.ExpiresAt) {
+
+			return ErrResetTokenInvalid
+		}
+		model.UsedAt = &now
+		if err := tx.Model(model).UpdateColumn("used_at", now).Error; err != nil {
+
+			return err
+		}
+
+		return tx.Model(&models.User{}).Where("id = ?", model.UserID).UpdateColumn("password", password).Error
+	})
+	if err != nil {
+
+		return nil, err
+	}
+
+	return model, nil
+}
+
+func (r *repository) CreateOutboxMessage(ctx context.Context, m *models.OutboxMessage) error {
+	ctx, span := startSpan(ctx, "CreateOutboxMessage")
+	defer span.End()
+
+	return translateError(r.db.WithContext(ctx).Create(m).Error)
+}
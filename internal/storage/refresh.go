@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"gofermart/internal/models"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenExpired  = errors.New("refresh token expired")
+	ErrRefreshTokenReused   = errors.New("refresh token reused")
+)
+
+func (r *repository) CreateRefreshToken(ctx context.Context, m *models.RefreshToken) error {
+	ctx, span := startSpan(ctx, "CreateRefreshToken")
+	defer span.End()
+
+	return translateError(r.db.WithContext(ctx).Create(m).Error)
+}
+
+// RotateRefreshToken marks the presented token as used and stores its
+// successor in the same family. Presenting a token that was already used or
+// revoked revokes the whole family and its session.
+func (r *repository) RotateRefreshToken(ctx context.Context, hash string, next *models.RefreshToken) (*models.RefreshToken, error) {
+	ctx, span := startSpan(ctx, "RotateRefreshToken")
+	defer span.End()
+
+	current := &models.RefreshToken{}
+	reused := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hash).First(current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+
+				return ErrRefreshTokenNotFound
+			}
+
+			return err
+		}
+		now := time.Now()
+		if current.UsedAt != nil || current.RevokedAt != nil {
+			reused = true
+			if err := tx.Model(&models.RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", current.FamilyID).
+				UpdateColumn("revoked_at", now).Error; err != nil {
+
+				return err
+			}
+
+			return tx.Model(&models.Session{}).Where("id = ? AND revoked_at IS NULL", current.SessionID).
+				UpdateColumn("revoked_at", now).Error
+		}
+		if !now.Before(current.ExpiresAt) {
+
+			return ErrRefreshTokenExpired
+		}
+		if err := tx.Model(current).UpdateColumn("used_at", now).Error; err != nil {
+
+			return err
+		}
+		next.UserID = current.UserID
+		next.SessionID = current.SessionID
+		next.FamilyID = current.FamilyID
+
+		return tx.Create(next).Error
+	})
+	if err == nil && reused {
+		err = ErrRefreshTokenReused
+	}
+
+	return current, translateError(err)
+}
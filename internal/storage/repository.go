@@ -1,29 +1,58 @@
 package storage
 
 import (
-	"log"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"gofermart/internal/config"
 	"gofermart/internal/models"
 )
 
 type Repository interface {
-	UserRegistered(login string) *models.User
-	RegisterUser(model *models.User) error
-	LoginUser(login string, password string) *models.User
-	GetUser(password string) *models.User
-	GetOrder(order int) *models.Order
-	SetOrder(*models.Order) error
-	GetOrders(id uint64) []models.Order
-	SetWithdraw(*models.Balance) error
-	GetWithdraws(id uint64) []models.Balance
-	SetAccrual(id int, status string, accrual float64) error
-	GetOrdersByStatus() []models.Order
+	UserRegistered(ctx context.Context, login string) (*models.User, error)
+	RegisterUser(ctx context.Context, model *models.User) error
+	GetUser(ctx context.Context, id uint64) *models.User
+	UpdatePassword(ctx context.Context, id uint64, hash string) error
+	GetOrder(ctx context.Context, number models.OrderNumber) (*models.Order, error)
+	SetOrder(ctx context.Context, m *models.Order) error
+	GetOrders(ctx context.Context, id uint64) []models.Order
+	Withdraw(ctx context.Context, m *models.Balance) error
+	GetWithdraws(ctx context.Context, id uint64) []models.Balance
+	GetAccount(ctx context.Context, id uint64) *models.Account
+	PostEntry(ctx context.Context, entry *models.JournalEntry) error
+	Adjust(ctx context.Context, userID uint64, amount models.Money, key string, description string) error
+	LedgerBalance(ctx context.Context, userID uint64) (models.Money, error)
+	LedgerStatement(ctx context.Context, userID uint64) ([]models.StatementLine, error)
+	CreateSession(ctx context.Context, m *models.Session) error
+	GetSession(ctx context.Context, id string) *models.Session
+	TouchSession(ctx context.Context, id string, lastSeen time.Time, expires time.Time) error
+	RevokeSession(ctx context.Context, id string) error
+	RevokeUserSessions(ctx context.Context, userID uint64, except string) ([]string, error)
+	GetSessions(ctx context.Context, userID uint64) []models.Session
+	CreateRefreshToken(ctx context.Context, m *models.RefreshToken) error
+	RotateRefreshToken(ctx context.Context, hash string, next *models.RefreshToken) (*models.RefreshToken, error)
+	UpdateLoginAttempts(ctx context.Context, keys []string, fn func(attempts map[string]*models.LoginAttempt) error) error
+	CreateAuditEvent(ctx context.Context, m *models.AuditEvent) error
+	CreatePasswordReset(ctx context.Context, m *models.PasswordReset) error
+	UsePasswordReset(ctx context.Context, hash string, password string) (*models.PasswordReset, error)
+	CreateOutboxMessage(ctx context.Context, m *models.OutboxMessage) error
+	SetAccrual(ctx context.Context, orderNumber models.OrderNumber, status string, accrual models.Money) error
+	CountPendingOrders(ctx context.Context) (int64, error)
+	ClaimOrders(ctx context.Context, limit int, lease time.Duration) ([]models.Order, error)
+	RescheduleOrder(ctx context.Context, orderNumber models.OrderNumber, attempts int, next time.Time) error
+	MarkOrderNotRegistered(ctx context.Context, orderNumber models.OrderNumber, next time.Time) error
+	SQLDB() (*sql.DB, error)
 }
 
+var pendingStatuses = []string{"NEW", "REGISTERED", "PROCESSING"}
+
 type repository struct {
 	db *gorm.DB
 }
@@ -32,53 +61,85 @@ type DB struct {
 	Repo Repository
 }
 
-func NewDB() *DB {
-	repo := NewRepository(config.GetConfigDBAddress())
+func NewDB(logger *slog.Logger) (*DB, error) {
+	repo, err := NewRepository(config.GetConfigDBAddress(), logger, config.GetConfigAutoMigrate())
+	if err != nil {
+
+		return nil, err
+	}
 
 	return &DB{
 		Repo: repo,
-	}
+	}, nil
 }
 
-func NewRepository(dns string) Repository {
-	db, err := gorm.Open(postgres.Open(dns), &gorm.Config{})
+// NewRepository connects to PostgreSQL and, when migrate is set, applies
+// pending schema migrations first.
+func NewRepository(dns string, logger *slog.Logger, migrate bool) (Repository, error) {
+	db, err := gorm.Open(postgres.Open(dns), &gorm.Config{Logger: newGormLogger(logger)})
 	if err != nil {
-		log.Fatal("Gorm repository failed %w", err.Error())
-	}
-	if exist := db.Migrator().HasTable(&models.User{}); !exist {
-		db.Migrator().CreateTable(&models.User{})
+
+		return nil, fmt.Errorf("database connection failed: %w", err)
 	}
-	if exist := db.Migrator().HasTable(&models.Order{}); !exist {
-		db.Migrator().CreateTable(&models.Order{})
+	if err := registerTracing(db); err != nil {
+
+		return nil, fmt.Errorf("gorm tracing registration failed: %w", err)
 	}
-	if exist := db.Migrator().HasTable(&models.Balance{}); !exist {
-		db.Migrator().CreateTable(&models.Balance{})
+	if migrate {
+		sqlDB, err := db.DB()
+		if err != nil {
+
+			return nil, err
+		}
+		migrator, err := NewMigrator(sqlDB, logger)
+		if err != nil {
+
+			return nil, err
+		}
+		if _, err := migrator.Up(context.Background()); err != nil {
+
+			return nil, fmt.Errorf("migrations failed: %w", err)
+		}
 	}
 
-	return &repository{db}
+	return &repository{db}, nil
 }
 
-func (r *repository) UserRegistered(login string) *models.User {
+// UserRegistered returns the user with the login, case-insensitively; its ID
+// is zero when there is none.
+func (r *repository) UserRegistered(ctx context.Context, login string) (*models.User, error) {
+	ctx, span := startSpan(ctx, "UserRegistered")
+	defer span.End()
+
 	model := &models.User{}
-	if err := r.db.Limit(1).Find(model, "login = ?", login).Error; err != nil {
+	if err := r.db.WithContext(ctx).Limit(1).Find(model, "lower(login) = lower(?)", login).Error; err != nil {
 
-		return nil
+		return nil, err
 	}
 
-	return model
+	return model, nil
 }
 
-func (r *repository) RegisterUser(m *models.User) error {
-	if err := r.db.Create(m).Error; err != nil {
-		return err
-	}
+func (r *repository) RegisterUser(ctx context.Context, m *models.User) error {
+	ctx, span := startSpan(ctx, "RegisterUser")
+	defer span.End()
+
+	return translateError(r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(m).Error; err != nil {
 
-	return nil
+			return err
+		}
+
+		return tx.Create(&models.Account{UserID: m.ID}).Error
+	}))
 }
 
-func (r *repository) LoginUser(login string, password string) *models.User {
+func (r *repository) GetUser(ctx context.Context, id uint64) *models.User {
+	ctx, span := startSpan(ctx, "GetUser")
+	defer span.End()
+
 	model := &models.User{}
-	if err := r.db.Limit(1).Find(model, "login = ? AND password = ?", login, password).Error; err != nil {
+	if err := r.db.WithContext(ctx).Limit(1).Find(model, "id = ?", id).Error; err != nil || model.ID == 0 {
 
 		return nil
 	}
@@ -86,72 +147,221 @@ func (r *repository) LoginUser(login string, password string) *models.User {
 	return model
 }
 
-func (r *repository) GetUser(password string) *models.User {
-	model := &models.User{}
-	if err := r.db.Limit(1).Find(model, "password = ?", password).Error; err != nil {
+func (r *repository) UpdatePassword(ctx context.Context, id uint64, hash string) error {
+	ctx, span := startSpan(ctx, "UpdatePassword")
+	defer span.End()
 
-		return nil
-	}
-
-	return model
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).UpdateColumn("password", hash).Error
 }
 
-func (r *repository) GetOrder(order int) *models.Order {
+// GetOrder returns the order with the number; its ID is zero when there is
+// none.
+func (r *repository) GetOrder(ctx context.Context, number models.OrderNumber) (*models.Order, error) {
+	ctx, span := startSpan(ctx, "GetOrder")
+	defer span.End()
+
 	model := &models.Order{}
-	if err := r.db.Limit(1).Find(model, "order_number = ?", order).Error; err != nil {
+	if err := r.db.WithContext(ctx).Limit(1).Find(model, "order_number = ?", number).Error; err != nil {
 
-		return nil
+		return nil, err
 	}
 
-	return model
+	return model, nil
 }
 
-func (r *repository) SetOrder(m *models.Order) error {
-	if err := r.db.Create(m).Error; err != nil {
-		return err
-	}
+func (r *repository) SetOrder(ctx context.Context, m *models.Order) error {
+	ctx, span := startSpan(ctx, "SetOrder")
+	defer span.End()
 
-	return nil
+	return translateError(r.db.WithContext(ctx).Create(m).Error)
 }
 
-func (r *repository) GetOrders(id uint64) []models.Order {
+func (r *repository) GetOrders(ctx context.Context, id uint64) []models.Order {
+	ctx, span := startSpan(ctx, "GetOrders")
+	defer span.End()
+
 	orders := []models.Order{}
-	r.db.Where("user_id = ?", id).Order("created_at desc").Find(&orders)
+	r.db.WithContext(ctx).Where("user_id = ?", id).Order("created_at desc").Find(&orders)
 
 	return orders
 }
 
-func (r *repository) SetWithdraw(m *models.Balance) error {
-	if err := r.db.Create(m).Error; err != nil {
-		return err
-	}
+// Withdraw checks the user's balance and stores the withdrawal in a single
+// transaction. The account row stays locked until commit, so concurrent
+// withdrawals of the same user are serialized and cannot overdraw.
+func (r *repository) Withdraw(ctx context.Context, m *models.Balance) error {
+	ctx, span := startSpan(ctx, "Withdraw")
+	defer span.End()
+
+	return translateError(r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		account, err := lockAccount(tx, m.UserID)
+		if err != nil {
+
+			return err
+		}
+		if account.Current < m.Withdraw {
+
+			return ErrInsufficientFunds
+		}
+		if err := tx.Create(m).Error; err != nil {
+
+			return err
+		}
+		// the balances row is committed only if the account is debited too
+		posted, err := postEntry(tx, withdrawalEntry(m))
+		if err == nil && !posted {
+			err = fmt.Errorf("%w: withdrawal %s", ErrEntryConflict, m.OrderID)
+		}
 
-	return nil
+		return err
+	}))
 }
 
-func (r *repository) GetWithdraws(id uint64) []models.Balance {
+func (r *repository) GetWithdraws(ctx context.Context, id uint64) []models.Balance {
+	ctx, span := startSpan(ctx, "GetWithdraws")
+	defer span.End()
+
 	balances := []models.Balance{}
-	r.db.Where("user_id = ?", id).Order("updated_at desc").Find(&balances)
+	r.db.WithContext(ctx).Where("user_id = ?", id).Order("updated_at desc").Find(&balances)
 
 	return balances
 }
 
-func (r *repository) SetAccrual(orderNumber int, status string, accrual float64) error {
-	model := &models.Order{}
-	if err := r.db.Limit(1).Find(model, "order_number  = ?", orderNumber).Error; err != nil {
+// SetAccrual updates the order and posts the accrual difference to the
+// ledger in the same transaction.
+func (r *repository) SetAccrual(ctx context.Context, orderNumber models.OrderNumber, status string, accrual models.Money) error {
+	ctx, span := startSpan(ctx, "SetAccrual")
+	defer span.End()
+
+	return translateError(r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		model := &models.Order{}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("order_number = ?", orderNumber).First(model).Error; err != nil {
+
+			return err
+		}
+		previous, changedAt := model.Accrual, model.UpdatedAt
+		model.Accrual = accrual
+		model.Status = status
+		if err := tx.Save(model).Error; err != nil {
+
+			return err
+		}
+		if accrual == previous {
+
+			return nil
+		}
+		// a skipped entry would leave the order and the account apart, so the
+		// order update is rolled back with it
+		posted, err := postEntry(tx, accrualEntry(model, previous, changedAt))
+		if err == nil && !posted {
+			err = fmt.Errorf("%w: order %s", ErrEntryConflict, orderNumber)
+		}
 
 		return err
-	}
-	model.Accrual = accrual
-	model.Status = status
-	r.db.Save(&model)
+	}))
+}
+
+func (r *repository) CountPendingOrders(ctx context.Context) (int64, error) {
+	ctx, span := startSpan(ctx, "CountPendingOrders")
+	defer span.End()
+
+	count := int64(0)
+	err := r.db.WithContext(ctx).Model(&models.Order{}).Where("status IN ?", pendingStatuses).Count(&count).Error
 
-	return nil
+	return count, err
 }
 
-func (r *repository) GetOrdersByStatus() []models.Order {
+// ClaimOrders picks up to limit pending orders that are due for polling and
+// hides them from other dispatchers for the lease duration.
+func (r *repository) ClaimOrders(ctx context.Context, limit int, lease time.Duration) ([]models.Order, error) {
+	ctx, span := startSpan(ctx, "ClaimOrders")
+	defer span.End()
+
 	orders := []models.Order{}
-	r.db.Where("status IN ?", []string{"NEW", "REGISTERED", "PROCESSING"}).Find(&orders)
+	now := time.Now()
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Raw(
+			`SELECT * FROM orders
+			WHERE status IN ? AND (next_poll_at IS NULL OR next_poll_at <= ?)
+			ORDER BY next_poll_at NULLS FIRST
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED`,
+			pendingStatuses, now, limit,
+		).Scan(&orders).Error
+		if err != nil || len(orders) == 0 {
+
+			return err
+		}
+		ids := make([]uint64, 0, len(orders))
+		for _, order := range orders {
+			ids = append(ids, order.ID)
+		}
+
+		return tx.Model(&models.Order{}).Where("id IN ?", ids).UpdateColumn("next_poll_at", now.Add(lease)).Error
+	})
+	if err != nil {
 
-	return orders
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+func (r *repository) RescheduleOrder(ctx context.Context, orderNumber models.OrderNumber, attempts int, next time.Time) error {
+	ctx, span := startSpan(ctx, "RescheduleOrder")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Model(&models.Order{}).Where("order_number = ?", orderNumber).UpdateColumns(map[string]interface{}{
+		"poll_attempts": attempts,
+		"next_poll_at":  next,
+	}).Error
+}
+
+// MarkOrderNotRegistered counts one more "204 No Content" answer for the order
+// and schedules the next check.
+func (r *repository) MarkOrderNotRegistered(ctx context.Context, orderNumber models.OrderNumber, next time.Time) error {
+	ctx, span := startSpan(ctx, "MarkOrderNotRegistered")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Model(&models.Order{}).Where("order_number = ?", orderNumber).UpdateColumns(map[string]interface{}{
+		"not_registered_attempts": gorm.Expr("not_registered_attempts + 1"),
+		"poll_attempts":           0,
+		"next_poll_at":            next,
+	}).Error
+}
+
+// SQLDB exposes the connection pool under gorm for stats and health checks.
+func (r *repository) SQLDB() (*sql.DB, error) {
+
+	return r.db.DB()
+}
+
+func (r *repository) GetAccount(ctx context.Context, id uint64) *models.Account {
+	ctx, span := startSpan(ctx, "GetAccount")
+	defer span.End()
+
+	model := &models.Account{UserID: id}
+	if err := r.db.WithContext(ctx).Limit(1).Find(model, "user_id = ?", id).Error; err != nil {
+
+		return nil
+	}
+
+	return model
+}
+
+// lockAccount returns the user's account locked for update, creating an empty
+// one first for users registered before accounts existed.
+func lockAccount(tx *gorm.DB, userID uint64) (*models.Account, error) {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.Account{UserID: userID}).Error; err != nil {
+
+		return nil, err
+	}
+	account := &models.Account{}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(account, "user_id = ?", userID).Error; err != nil {
+
+		return nil, err
+	}
+
+	return account, nil
 }
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"gofermart/internal/models"
+)
+
+func (r *repository) CreateSession(ctx context.Context, m *models.Session) error {
+	ctx, span := startSpan(ctx, "CreateSession")
+	defer span.End()
+
+	return translateError(r.db.WithContext(ctx).Create(m).Error)
+}
+
+func (r *repository) GetSession(ctx context.Context, id string) *models.Session {
+	ctx, span := startSpan(ctx, "GetSession")
+	defer span.End()
+
+	model := &models.Session{}
+	if err := r.db.WithContext(ctx).Limit(1).Find(model, "id = ?", id).Error; err != nil || model.ID == "" {
+
+		return nil
+	}
+
+	return model
+}
+
+func (r *repository) TouchSession(ctx context.Context, id string, lastSeen time.Time, expires time.Time) error {
+	ctx, span := startSpan(ctx, "TouchSession")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Model(&models.Session{}).Where("id = ? AND revoked_at IS NULL", id).UpdateColumns(map[string]interface{}{
+		"last_seen_at": lastSeen,
+		"expires_at":   expires,
+	}).Error
+}
+
+// RevokeSession revokes the session together with its refresh tokens.
+func (r *repository) RevokeSession(ctx context.Context, id string) error {
+	ctx, span := startSpan(ctx, "RevokeSession")
+	defer span.End()
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.Session{}).Where("id = ? AND revoked_at IS NULL", id).
+			UpdateColumn("revoked_at", now).Error; err != nil {
+
+			return err
+		}
+
+		return tx.Model(&models.RefreshToken{}).Where("session_id = ? AND revoked_at IS NULL", id).
+			UpdateColumn("revoked_at", now).Error
+	})
+}
+
+// RevokeUserSessions revokes every active session of the user except the
+// given one and returns the IDs it revoked.
+func (r *repository) RevokeUserSessions(ctx context.Context, userID uint64, except string) ([]string, error) {
+	ctx, span := startSpan(ctx, "RevokeUserSessions")
+	defer span.End()
+
+	ids := []string{}
+	err := r.db.WithContext(ctx).Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL AND expires_at > ?", userID, except, time.Now()).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+
+		return ids, err
+	}
+
+	return ids, r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&models.Session{}).Where("id IN ?", ids).UpdateColumn("revoked_at", now).Error; err != nil {
+
+			return err
+		}
+
+		return tx.Model(&models.RefreshToken{}).Where("session_id IN ? AND revoked_at IS NULL", ids).
+			UpdateColumn("revoked_at", now).Error
+	})
+}
+
+func (r *repository) GetSessions(ctx context.Context, userID uint64) []models.Session {
+	ctx, span := startSpan(ctx, "GetSessions")
+	defer span.End()
+
+	sessions := []models.Session{}
+	r.db.WithContext(ctx).Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at desc").Find(&sessions)
+
+	return sessions
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "tracing:span"
+
+var tracer = otel.Tracer("gofermart/internal/storage")
+
+// startSpan opens the span of a repository method.
+func startSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+
+	return tracer.Start(ctx, "storage."+method,
+		trace.WithAttributes(attribute.String("db.system", "postgresql")))
+}
+
+// registerTracing adds a child span around every query gorm runs, so the
+// spans of repository methods show where their time goes.
+func registerTracing(db *gorm.DB) error {
+	type register func(name string, fn func(*gorm.DB)) error
+	callbacks := []struct {
+		operation string
+		before    register
+		after     register
+	}{
+		{"create", db.Callback().Create().Before("gorm:create").Register, db.Callback().Create().After("gorm:create").Register},
+		{"query", db.Callback().Query().Before("gorm:query").Register, db.Callback().Query().After("gorm:query").Register},
+		{"update", db.Callback().Update().Before("gorm:update").Register, db.Callback().Update().After("gorm:update").Register},
+		{"delete", db.Callback().Delete().Before("gorm:delete").Register, db.Callback().Delete().After("gorm:delete").Register},
+		{"row", db.Callback().Row().Before("gorm:row").Register, db.Callback().Row().After("gorm:row").Register},
+		{"raw", db.Callback().Raw().Before("gorm:raw").Register, db.Callback().Raw().After("gorm:raw").Register},
+	}
+	for _, c := range callbacks {
+		operation := c.operation
+		err := c.before("tracing:before_"+operation, func(tx *gorm.DB) {
+			_, span := tracer.Start(tx.Statement.Context, "gorm."+operation,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(attribute.String("db.system", "postgresql")))
+			tx.InstanceSet(spanKey, span)
+		})
+		if err != nil {
+
+			return err
+		}
+		err = c.after("tracing:after_"+operation, func(tx *gorm.DB) {
+			value, ok := tx.InstanceGet(spanKey)
+			if !ok {
+
+				return
+			}
+			span := value.(trace.Span)
+			span.SetAttributes(
+				attribute.String("db.sql.table", tx.Statement.Table),
+				attribute.String("db.statement", tx.Statement.SQL.String()),
+				attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+			)
+			if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+				span.RecordError(tx.Error)
+				span.SetStatus(codes.Error, tx.Error.Error())
+			}
+			span.End()
+		})
+		if err != nil {
+
+			return err
+		}
+	}
+
+	return nil
+}
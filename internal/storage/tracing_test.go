@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"gofermart/internal/tracing"
+)
+
+func TestStartSpan(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewProvider(exporter)
+	otel.SetTracerProvider(provider)
+
+	parentCtx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	_, span := startSpan(parentCtx, "GetOrder")
+	span.End()
+	parent.End()
+	if err := provider.ForceFlush(context.Background()); err != nil {
+		t.Fatalf("ForceFlush: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	got := spans[0]
+	if got.Name != "storage.GetOrder" {
+		t.Errorf("span name = %q, want %q", got.Name, "storage.GetOrder")
+	}
+	if got.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("span parent = %s, want %s", got.Parent.SpanID(), parent.SpanContext().SpanID())
+	}
+	found := false
+	for _, attr := range got.Attributes {
+		if attr.Key == "db.system" && attr.Value.AsString() == "postgresql" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("span attributes %v lack db.system=postgresql", got.Attributes)
+	}
+}
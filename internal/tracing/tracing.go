@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const serviceName = "gophermart"
+
+// Setup installs the global tracer provider and W3C trace-context
+// propagation. exporter is "none", "stdout" or "otlp"; endpoint is the OTLP
+// HTTP collector URL, empty for the OTEL_EXPORTER_OTLP_* defaults.
+func Setup(ctx context.Context, exporter string, endpoint string) (*sdktrace.TracerProvider, error) {
+	var exp sdktrace.SpanExporter
+	switch exporter {
+	case "", "none":
+	case "stdout":
+		stdout, err := stdouttrace.New()
+		if err != nil {
+
+			return nil, err
+		}
+		exp = stdout
+	case "otlp":
+		options := []otlptracehttp.Option{}
+		if endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpointURL(endpoint))
+		}
+		otlp, err := otlptracehttp.New(ctx, options...)
+		if err != nil {
+
+			return nil, err
+		}
+		exp = otlp
+	default:
+
+		return nil, fmt.Errorf("trace exporter %q: want none, stdout or otlp", exporter)
+	}
+
+	provider := NewProvider(exp)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return provider, nil
+}
+
+// NewProvider returns a tracer provider sending spans to exp. Without an
+// exporter spans are still created, so trace IDs reach logs and outgoing
+// requests. Tests pass a tracetest.InMemoryExporter and ForceFlush.
+func NewProvider(exp sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	}
+	if exp != nil {
+		options = append(options, sdktrace.WithBatcher(exp))
+	}
+
+	return sdktrace.NewTracerProvider(options...)
+}